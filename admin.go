@@ -0,0 +1,363 @@
+// Copyright (c) 2014, Daniel Gallagher
+// Use of this source code is covered by the MIT License, the full
+// text of which can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/danielgallagher0/docwiki/wikilang"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const doclinksPath = "/admin/doclinks"
+const reindexPath = "/admin/reindex"
+const docChangesPath = "/doc-changes/"
+
+// A DocLinkProblem is a doclink on a wiki page whose entity no longer
+// exists in the project's Doxygen, or a link to a section of a page
+// that the page does not have.
+type DocLinkProblem struct {
+	Page       string   // Title of the page containing the doclink
+	Link       string   // The doclink, e.g., doc:project:entity
+	Status     string   // One of the wikilang.DocLink* statuses
+	Candidates []string // Entities the link's entity may have become
+}
+
+// doclinkReport checks every doclink on every page in the wiki.
+func doclinkReport() []DocLinkProblem {
+	problems := []DocLinkProblem{}
+
+	for _, title := range listPages() {
+		p, err := loadPage(title)
+		if err != nil {
+			continue
+		}
+		p = p.view()
+
+		for _, link := range wikilang.Links(string(p.Body), p.renderOptions()) {
+			parts := strings.SplitN(link, ":", 3)
+			if len(parts) != 3 || parts[0] != "doc" {
+				continue
+			}
+
+			status, candidates := wikilang.CheckDocLink(parts[1], parts[2])
+			if status != wikilang.DocLinkOk {
+				problems = append(problems, DocLinkProblem{title, link, status, candidates})
+			}
+		}
+	}
+
+	return problems
+}
+
+// A reindexCheck is the doclinks to a version of a project that were
+// broken the last time its entities changed.
+type reindexCheck struct {
+	at       time.Time
+	problems []DocLinkProblem
+}
+
+// reindexChecks holds the last reindexCheck of each version of each
+// project, keyed by project@version.
+var reindexChecks struct {
+	sync.Mutex
+	checks map[string]reindexCheck
+}
+
+// fullSpec returns the project@version that a project specification
+// names, using the project's default version if it names none.
+func fullSpec(spec string) string {
+	project, version := wikilang.SplitVersion(spec)
+	if version == "" {
+		_, version, _ = wikilang.DocVersions(project)
+	}
+	return wikilang.DocUrlName(project, version)
+}
+
+// reindexProblems returns the doclinks to a version of a project whose
+// entities were removed or moved by changes.  Links to entities that
+// moved have the entity's new name as their candidate.  Links that
+// were broken before the changes are not included.
+func reindexProblems(spec string, changes wikilang.IndexChanges) []DocLinkProblem {
+	problems := []DocLinkProblem{}
+
+	gone := map[string][]string{}
+	for _, name := range changes.Removed {
+		gone[name] = nil
+	}
+	for _, move := range changes.Moved {
+		gone[move.From] = []string{move.To}
+	}
+	if len(gone) == 0 {
+		return problems
+	}
+
+	spec = fullSpec(spec)
+	for _, title := range listPages() {
+		p, err := loadPage(title)
+		if err != nil {
+			continue
+		}
+		p = p.view()
+
+		for _, link := range wikilang.Links(string(p.Body), p.renderOptions()) {
+			parts := strings.SplitN(link, ":", 3)
+			if len(parts) != 3 || parts[0] != "doc" || fullSpec(parts[1]) != spec {
+				continue
+			}
+
+			candidates, ok := gone[parts[2]]
+			if !ok {
+				continue
+			}
+			status := wikilang.DocLinkRemoved
+			if len(candidates) > 0 {
+				status = wikilang.DocLinkRenameCandidate
+			}
+			problems = append(problems, DocLinkProblem{title, link, status, candidates})
+		}
+	}
+
+	return problems
+}
+
+// recordReindexCheck finds the doclinks broken by changes to a version
+// of a project's entities, and keeps them for the doclink report.
+func recordReindexCheck(spec string, changes wikilang.IndexChanges) []DocLinkProblem {
+	problems := reindexProblems(spec, changes)
+
+	reindexChecks.Lock()
+	defer reindexChecks.Unlock()
+	if reindexChecks.checks == nil {
+		reindexChecks.checks = map[string]reindexCheck{}
+	}
+	reindexChecks.checks[fullSpec(spec)] = reindexCheck{time.Now(), problems}
+	return problems
+}
+
+// checkReindexedProject checks the doclinks to a project whose
+// entities have just changed, so that links broken by regenerating
+// its Doxygen are reported without anyone opening the doclink report.
+// Broken links are written to stderr, and kept for the report.
+func checkReindexedProject(spec string) {
+	changes, ok := wikilang.Changes(spec)
+	if !ok {
+		return
+	}
+
+	if problems := recordReindexCheck(spec, changes); len(problems) > 0 {
+		fmt.Fprintf(os.Stderr, "Reindexing %s broke %d doclinks; see %s\n", spec, len(problems), doclinksPath)
+	}
+}
+
+// writeReindexCheck writes the doclinks that were broken the last time
+// each project's entities changed as wiki text.
+func writeReindexCheck(w io.Writer) {
+	reindexChecks.Lock()
+	defer reindexChecks.Unlock()
+
+	fmt.Fprintf(w, "/*Last reindex*/\n\n")
+	if len(reindexChecks.checks) == 0 {
+		fmt.Fprintf(w, "No project's entities have changed since the wiki started.\n\n")
+		return
+	}
+
+	specs := []string{}
+	for spec := range reindexChecks.checks {
+		specs = append(specs, spec)
+	}
+	sort.Strings(specs)
+
+	for _, spec := range specs {
+		check := reindexChecks.checks[spec]
+		fmt.Fprintf(w, "When {%s} was reindexed at %s, %d doclinks to it were broken.\n\n", spec,
+			check.at.Format("2006-01-02 15:04:05"), len(check.problems))
+		for _, problem := range check.problems {
+			fmt.Fprintf(w, "    - [%s]: {%s} %s", problem.Page, problem.Link, problem.Status)
+			for _, candidate := range problem.Candidates {
+				fmt.Fprintf(w, " {%s}", candidate)
+			}
+			fmt.Fprintf(w, "\n")
+		}
+		if len(check.problems) > 0 {
+			fmt.Fprintf(w, "\n")
+		}
+	}
+}
+
+// anchorMissing is the status of a link to a section of a page that
+// has no heading or anchor with that name.
+const anchorMissing = "missing-anchor"
+
+// anchorReport checks every link to a section of a page, such as
+// [Page#Section], on every page in the wiki.  Links to pages that do
+// not exist are not checked.
+func anchorReport() []DocLinkProblem {
+	problems := []DocLinkProblem{}
+	anchors := map[string]map[string]bool{}
+
+	for _, title := range listPages() {
+		p, err := loadPage(title)
+		if err != nil {
+			continue
+		}
+		p = p.view()
+
+		for _, link := range wikilang.Links(string(p.Body), p.renderOptions()) {
+			target, section := wikilang.SplitFragment(link)
+			if section == "" || strings.Contains(target, ":") {
+				continue
+			}
+			if target == "" {
+				target = title
+			}
+
+			ids, ok := anchors[target]
+			if !ok {
+				ids = pageAnchors(target)
+				anchors[target] = ids
+			}
+			if ids != nil && !ids[wikilang.AnchorId(section)] {
+				problems = append(problems, DocLinkProblem{title, link, anchorMissing, nil})
+			}
+		}
+	}
+
+	return problems
+}
+
+// pageAnchors returns the ids of the sections of a page, after
+// following redirects, or nil if there is no such page.
+func pageAnchors(title string) map[string]bool {
+	title, err := followRedirects(title)
+	if err != nil {
+		return nil
+	}
+	p, err := loadPage(title)
+	if err != nil {
+		return nil
+	}
+	p = p.view()
+
+	ids := map[string]bool{}
+	for _, id := range wikilang.Anchors(string(p.Body), p.renderOptions()) {
+		ids[id] = true
+	}
+	return ids
+}
+
+// writeDocLinkReport writes the problems with doclinks and with links
+// to sections of pages as wiki text.
+func writeDocLinkReport(w io.Writer, problems, anchorProblems []DocLinkProblem) {
+	fmt.Fprintf(w, "/*Broken doclinks*/\n\n")
+	if len(problems) == 0 {
+		fmt.Fprintf(w, "All doclinks are up to date.\n")
+	}
+
+	for _, problem := range problems {
+		fmt.Fprintf(w, "    - [%s]: {%s} %s", problem.Page, problem.Link, problem.Status)
+		for _, candidate := range problem.Candidates {
+			fmt.Fprintf(w, " {%s}", candidate)
+		}
+		fmt.Fprintf(w, "\n")
+	}
+
+	fmt.Fprintf(w, "\n/*Broken section links*/\n\n")
+	if len(anchorProblems) == 0 {
+		fmt.Fprintf(w, "All links to sections of pages go to a heading or anchor.\n")
+	}
+
+	for _, problem := range anchorProblems {
+		fmt.Fprintf(w, "    - [%s]: {%s} %s\n", problem.Page, problem.Link, problem.Status)
+	}
+
+	fmt.Fprintf(w, "\n/*Changes from the previous snapshot*/\n\n")
+	for _, project := range wikilang.Projects() {
+		versions, _, _ := wikilang.DocVersions(project)
+		for _, version := range versions {
+			name := wikilang.DocUrlName(project, version)
+			changes, _ := wikilang.Changes(name)
+			fmt.Fprintf(w, "    - [%s:../doc-changes/%s]: %d added, %d removed, %d moved\n", name, name,
+				len(changes.Added), len(changes.Removed), len(changes.Moved))
+		}
+	}
+}
+
+// docChangesHandler shows the changes between the last two snapshots
+// of a project at /doc-changes/<project>.
+func docChangesHandler(w http.ResponseWriter, r *http.Request) {
+	spec := r.URL.Path[len(docChangesPath):]
+	if _, ok := wikilang.Changes(spec); !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	body := fmt.Sprintf("[docchanges:%s]\n", spec)
+	renderTemplate(w, "report", &Page{Title: "DocChanges", Body: []byte(body)})
+}
+
+// doclinksHandler shows the doclinks broken by the last reindex, and
+// checks every link in the wiki.
+func doclinksHandler(w http.ResponseWriter, r *http.Request) {
+	var body bytes.Buffer
+	writeReindexCheck(&body)
+	writeDocLinkReport(&body, doclinkReport(), anchorReport())
+	renderTemplate(w, "doclinks", &Page{Title: "DocLinkReport", Body: body.Bytes()})
+}
+
+// reindexHandler rereads the search data for the posted project, or
+// for every version of every project if none is given, and records
+// the doclinks that the changes to each one's entities broke.
+func reindexHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "reindex requires POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	specs := []string{}
+	if project := r.FormValue("project"); project != "" {
+		specs = append(specs, project)
+	} else {
+		for _, project := range wikilang.Projects() {
+			versions, _, _ := wikilang.DocVersions(project)
+			for _, version := range versions {
+				specs = append(specs, wikilang.DocUrlName(project, version))
+			}
+		}
+	}
+
+	for _, spec := range specs {
+		changes, err := wikilang.Reindex(spec)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		recordReindexCheck(spec, changes)
+	}
+
+	http.Redirect(w, r, proxyRoot()+doclinksPath, http.StatusFound)
+}
+
+// checkCommand writes every broken doclink and link to a missing
+// section to w, one per line, and returns the exit status for the
+// check command: 0 if all links are fine and 1 otherwise.
+func checkCommand(w io.Writer) int {
+	problems := append(doclinkReport(), anchorReport()...)
+	for _, problem := range problems {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", problem.Page, problem.Link, problem.Status,
+			strings.Join(problem.Candidates, ","))
+	}
+
+	if len(problems) > 0 {
+		return 1
+	}
+	return 0
+}
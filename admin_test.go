@@ -0,0 +1,103 @@
+// Copyright (c) 2014, Daniel Gallagher
+// Use of this source code is covered by the MIT License, the full
+// text of which can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/danielgallagher0/docwiki/wikilang"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestCheckCommand(t *testing.T) {
+	var out bytes.Buffer
+	if status := checkCommand(&out); status != 0 {
+		t.Errorf("Expected status 0, got %d", status)
+	}
+	compare(t, out.String(), "")
+}
+
+func TestWriteDocLinkReport(t *testing.T) {
+	var out bytes.Buffer
+	writeDocLinkReport(&out, []DocLinkProblem{
+		{"SomePage", "doc:big:Gone", "removed", nil},
+		{"OtherPage", "doc:big:old::Window", "renamed-candidate", []string{"gui::Window", "tk::Window"}},
+	}, []DocLinkProblem{
+		{"SomePage", "OtherPage#Missing", "missing-anchor", nil},
+	})
+	compare(t, out.String(), "/*Broken doclinks*/\n\n"+
+		"    - [SomePage]: {doc:big:Gone} removed\n"+
+		"    - [OtherPage]: {doc:big:old::Window} renamed-candidate {gui::Window} {tk::Window}\n"+
+		"\n/*Broken section links*/\n\n"+
+		"    - [SomePage]: {OtherPage#Missing} missing-anchor\n"+
+		"\n/*Changes from the previous snapshot*/\n\n")
+}
+
+func TestReindexCheck(t *testing.T) {
+	var out bytes.Buffer
+	writeReindexCheck(&out)
+	compare(t, out.String(), "/*Last reindex*/\n\nNo project's entities have changed since the wiki started.\n\n")
+
+	(&Page{Title: "ReindexCheckTest", Body: []byte("[doc:reindexed@2:Gone] [doc:reindexed@2:Old] [doc:reindexed@2:Kept] " +
+		"[doc:reindexed@1:Gone] [doc:reindexed:Gone] [doc:other:Gone]")}).save()
+	defer removePage("ReindexCheckTest")
+	changes := wikilang.IndexChanges{
+		Added:   []string{"ns::Old"},
+		Removed: []string{"Gone"},
+		Moved:   []wikilang.EntityMove{{From: "Old", To: "ns::Old"}},
+	}
+	recordReindexCheck("reindexed@2", changes)
+	recordReindexCheck("reindexed@1", wikilang.IndexChanges{})
+
+	reindexChecks.Lock()
+	for spec, check := range reindexChecks.checks {
+		check.at = time.Date(2014, 5, 1, 12, 30, 0, 0, time.UTC)
+		reindexChecks.checks[spec] = check
+	}
+	reindexChecks.Unlock()
+	defer func() {
+		reindexChecks.Lock()
+		reindexChecks.checks = nil
+		reindexChecks.Unlock()
+	}()
+
+	out.Reset()
+	writeReindexCheck(&out)
+	compare(t, out.String(), "/*Last reindex*/\n\n"+
+		"When {reindexed@1} was reindexed at 2014-05-01 12:30:00, 0 doclinks to it were broken.\n\n"+
+		"When {reindexed@2} was reindexed at 2014-05-01 12:30:00, 2 doclinks to it were broken.\n\n"+
+		"    - [ReindexCheckTest]: {doc:reindexed@2:Gone} removed\n"+
+		"    - [ReindexCheckTest]: {doc:reindexed@2:Old} renamed-candidate {ns::Old}\n\n")
+}
+
+func TestAnchorReport(t *testing.T) {
+	pages := map[string]string{
+		"AnchorTestTarget": "/*First Section*/\n\nText [anchor:Explicit Spot]\n\n/*First Section*/",
+		"AnchorTestLinks": "[AnchorTestTarget#First Section] [AnchorTestTarget#first-section-2] [AnchorTestTarget#explicit-spot]\n\n" +
+			"[AnchorTestTarget#Gone] [#Local] [#Missing] [AnchorTestNowhere#Gone] [doc:big:x#y]\n\n*Local*",
+	}
+	for title, body := range pages {
+		(&Page{Title: title, Body: []byte(body)}).save()
+	}
+	defer func() {
+		for title := range pages {
+			os.Remove(pageFile(title))
+		}
+	}()
+
+	var out bytes.Buffer
+	for _, problem := range anchorReport() {
+		fmt.Fprintf(&out, "%s %s %s\n", problem.Page, problem.Link, problem.Status)
+	}
+	compare(t, out.String(), "AnchorTestLinks AnchorTestTarget#Gone missing-anchor\n"+
+		"AnchorTestLinks #Missing missing-anchor\n")
+
+	out.Reset()
+	if status := checkCommand(&out); status != 1 {
+		t.Errorf("Expected status 1, got %d", status)
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright (c) 2014, Daniel Gallagher
+// Use of this source code is covered by the MIT License, the full
+// text of which can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"github.com/danielgallagher0/docwiki/wikilang"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const completeDocPath = "/api/complete/doc"
+const completePagePath = "/api/complete/page"
+
+const defaultCompletionLimit = 20
+const maxCompletionLimit = 100
+
+// PageSuggestion is a possible completion for a partially written
+// wikilink.
+type PageSuggestion struct {
+	Title string `json:"title"`
+	Text  string `json:"text"`
+	Url   string `json:"url"`
+}
+
+func writeJson(w http.ResponseWriter, v interface{}) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(body)
+}
+
+func completionLimit(r *http.Request) int {
+	limit, err := strconv.Atoi(r.FormValue("limit"))
+	if err != nil || limit <= 0 {
+		return defaultCompletionLimit
+	}
+	if limit > maxCompletionLimit {
+		return maxCompletionLimit
+	}
+	return limit
+}
+
+// completeDocHandler suggests Doxygen entities for
+// /api/complete/doc?project=name&prefix=text
+func completeDocHandler(w http.ResponseWriter, r *http.Request) {
+	project := r.FormValue("project")
+	if project == "" {
+		http.Error(w, "missing project", http.StatusBadRequest)
+		return
+	}
+
+	writeJson(w, wikilang.CompleteDocLink(project, r.FormValue("prefix"), completionLimit(r)))
+}
+
+// completePageHandler suggests wiki pages for
+// /api/complete/page?prefix=text.  Titles match if they start with
+// the prefix, or if one of the words in the title does.
+func completePageHandler(w http.ResponseWriter, r *http.Request) {
+	prefix := r.FormValue("prefix")
+	lowerPrefix := strings.ToLower(prefix)
+
+	titles := []string{}
+	for _, entry := range indexedPages() {
+		title := entry.title
+		if strings.HasPrefix(strings.ToLower(title), lowerPrefix) {
+			titles = append(titles, title)
+			continue
+		}
+
+		for _, word := range strings.Fields(wikilang.WikiCase(title)) {
+			if strings.HasPrefix(strings.ToLower(word), lowerPrefix) {
+				titles = append(titles, title)
+				break
+			}
+		}
+	}
+
+	wikilang.RankCompletions(titles, prefix)
+	if limit := completionLimit(r); len(titles) > limit {
+		titles = titles[:limit]
+	}
+
+	suggestions := []PageSuggestion{}
+	for _, title := range titles {
+		suggestions = append(suggestions, PageSuggestion{title, displayTitle(title), proxyRoot() + viewPath + title})
+	}
+
+	writeJson(w, suggestions)
+}
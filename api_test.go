@@ -0,0 +1,68 @@
+// Copyright (c) 2014, Daniel Gallagher
+// Use of this source code is covered by the MIT License, the full
+// text of which can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCompletePage(t *testing.T) {
+	for _, data := range [...]struct {
+		query, expected string
+	}{
+		{"prefix=DocWiki", "DocWiki DocWikiLang DocWikiConfiguration"},
+		{"prefix=docwiki&limit=1", "DocWiki"},
+		{"prefix=Lang", "DocWikiLang"},
+		{"prefix=what", "WhatDocWikiIs WhatDocWikiIsNot"},
+		{"prefix=Nothing", ""},
+	} {
+		w := httptest.NewRecorder()
+		completePageHandler(w, httptest.NewRequest("GET", completePagePath+"?"+data.query, nil))
+
+		var suggestions []PageSuggestion
+		if err := json.Unmarshal(w.Body.Bytes(), &suggestions); err != nil {
+			t.Fatalf("Could not decode %s: %s", w.Body.String(), err)
+		}
+
+		titles := []string{}
+		for _, s := range suggestions {
+			titles = append(titles, s.Title)
+		}
+		compare(t, strings.Join(titles, " "), data.expected)
+	}
+}
+
+func TestCompletePageDisplayTitle(t *testing.T) {
+	(&Page{Title: "CompleteDisplayTest", Body: []byte("#title Shown Title\nText")}).save()
+	defer os.Remove(pageFile("CompleteDisplayTest"))
+
+	w := httptest.NewRecorder()
+	completePageHandler(w, httptest.NewRequest("GET", completePagePath+"?prefix=CompleteDisplay", nil))
+
+	var suggestions []PageSuggestion
+	if err := json.Unmarshal(w.Body.Bytes(), &suggestions); err != nil {
+		t.Fatalf("Could not decode %s: %s", w.Body.String(), err)
+	}
+	if len(suggestions) != 1 {
+		t.Fatalf("Expected 1 suggestion, got %v", suggestions)
+	}
+	compare(t, suggestions[0].Text, "Shown Title")
+}
+
+func TestCompleteDocRequiresProject(t *testing.T) {
+	w := httptest.NewRecorder()
+	completeDocHandler(w, httptest.NewRequest("GET", completeDocPath+"?prefix=Foo", nil))
+	if w.Code != 400 {
+		t.Errorf("Expected status 400, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	completeDocHandler(w, httptest.NewRequest("GET", completeDocPath+"?project=none&prefix=Foo", nil))
+	compare(t, w.Body.String(), "[]")
+}
@@ -0,0 +1,216 @@
+// Copyright (c) 2014, Daniel Gallagher
+// Use of this source code is covered by the MIT License, the full
+// text of which can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/danielgallagher0/docwiki/wikilang"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const coveragePath = "/admin/coverage/"
+
+// coverageKinds are the kinds of Doxygen entities that should have
+// mid-level documentation.  Functions only count if they are not
+// members of a class.
+var coverageKinds = map[string]bool{
+	"class":     true,
+	"struct":    true,
+	"union":     true,
+	"interface": true,
+	"enum":      true,
+	"function":  true,
+}
+
+var classKinds = map[string]bool{
+	"class":     true,
+	"struct":    true,
+	"union":     true,
+	"interface": true,
+}
+
+// A coverageGroup counts the documented entities of one kind in one
+// namespace.
+type coverageGroup struct {
+	Kind      string
+	Namespace string
+	Total     int
+	Covered   int
+	Missing   []string
+}
+
+func percent(covered, total int) int {
+	if total == 0 {
+		return 100
+	}
+	return covered * 100 / total
+}
+
+// namespaceOf returns the qualifier of a Doxygen name, or the empty
+// string for the global namespace.
+func namespaceOf(name string) string {
+	if i := strings.LastIndex(name, "::"); i >= 0 {
+		return name[:i]
+	}
+	return ""
+}
+
+// linkedEntities returns the set of entities in the project that are
+// the target of a doclink on any page.  Links to any version of the
+// project count.
+func linkedEntities(project string) map[string]bool {
+	linked := map[string]bool{}
+
+	for _, title := range listPages() {
+		p, err := loadPage(title)
+		if err != nil {
+			continue
+		}
+		p = p.view()
+
+		for _, link := range wikilang.Links(string(p.Body), p.renderOptions()) {
+			parts := strings.SplitN(link, ":", 3)
+			if len(parts) != 3 || parts[0] != "doc" {
+				continue
+			}
+
+			if name, _ := wikilang.SplitVersion(parts[1]); name == project {
+				linked[parts[2]] = true
+			}
+		}
+	}
+
+	return linked
+}
+
+// coverageReport groups the project's major entities by kind and
+// namespace, and counts how many of them have a doclink on some page.
+func coverageReport(project string) []coverageGroup {
+	entities := wikilang.Entities(project)
+	kinds := map[string]string{}
+	for _, e := range entities {
+		kinds[e.Name] = e.Kind
+	}
+
+	linked := linkedEntities(project)
+	groups := map[string]*coverageGroup{}
+	for _, e := range entities {
+		namespace := namespaceOf(e.Name)
+		if !coverageKinds[e.Kind] || (e.Kind == "function" && classKinds[kinds[namespace]]) {
+			continue
+		}
+
+		key := e.Kind + " " + namespace
+		group, ok := groups[key]
+		if !ok {
+			group = &coverageGroup{Kind: e.Kind, Namespace: namespace}
+			groups[key] = group
+		}
+
+		group.Total++
+		if linked[e.Name] {
+			group.Covered++
+		} else {
+			group.Missing = append(group.Missing, e.Name)
+		}
+	}
+
+	report := []coverageGroup{}
+	for _, group := range groups {
+		report = append(report, *group)
+	}
+	sort.Slice(report, func(i, j int) bool {
+		if report[i].Kind != report[j].Kind {
+			return report[i].Kind < report[j].Kind
+		}
+		return report[i].Namespace < report[j].Namespace
+	})
+
+	return report
+}
+
+var nonTitleCharacters = regexp.MustCompile("[^A-Za-z0-9]+")
+
+// stubTitle suggests a wiki page title for an entity.
+func stubTitle(name string) string {
+	title := ""
+	for _, word := range nonTitleCharacters.Split(name, -1) {
+		if len(word) > 0 {
+			title += strings.ToUpper(word[:1]) + word[1:]
+		}
+	}
+	return title
+}
+
+func coverageTotals(report []coverageGroup) (covered, total int) {
+	for _, group := range report {
+		covered += group.Covered
+		total += group.Total
+	}
+	return
+}
+
+// writeCoverageReport writes the report as wiki text.
+func writeCoverageReport(w io.Writer, project string, report []coverageGroup) {
+	covered, total := coverageTotals(report)
+	fmt.Fprintf(w, "%d of %d classes and functions in {%s} have wiki documentation (%d%%).\n",
+		covered, total, project, percent(covered, total))
+
+	kind := ""
+	for _, group := range report {
+		if group.Kind != kind {
+			kind = group.Kind
+			fmt.Fprintf(w, "\n/*%s*/\n\n", kind)
+		}
+
+		namespace := group.Namespace
+		if namespace == "" {
+			namespace = "(global)"
+		}
+		fmt.Fprintf(w, "    - {%s}: %d of %d (%d%%)\n", namespace, group.Covered, group.Total,
+			percent(group.Covered, group.Total))
+		for _, name := range group.Missing {
+			fmt.Fprintf(w, "        - [doc:%s:%s] [CreateStub:../entitypage/%s/%s]\n", project, name, project, url.PathEscape(name))
+		}
+	}
+}
+
+func coverageHandler(w http.ResponseWriter, r *http.Request) {
+	project := r.URL.Path[len(coveragePath):]
+	if _, _, ok := wikilang.DocVersions(project); !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	var body bytes.Buffer
+	writeCoverageReport(&body, project, coverageReport(project))
+	renderTemplate(w, "report", &Page{Title: "Coverage", Body: body.Bytes()})
+}
+
+// coverageCommand writes the coverage of a project to w, one line per
+// kind and namespace, followed by the total.  It returns the exit
+// status for the coverage command.
+func coverageCommand(w io.Writer, project string) int {
+	if _, _, ok := wikilang.DocVersions(project); !ok {
+		fmt.Fprintf(w, "Unknown project %s\n", project)
+		return 2
+	}
+
+	report := coverageReport(project)
+	for _, group := range report {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d%%\n", group.Kind, group.Namespace, group.Covered,
+			group.Total, percent(group.Covered, group.Total))
+	}
+
+	covered, total := coverageTotals(report)
+	fmt.Fprintf(w, "total\t\t%d\t%d\t%d%%\n", covered, total, percent(covered, total))
+	return 0
+}
@@ -0,0 +1,47 @@
+// Copyright (c) 2014, Daniel Gallagher
+// Use of this source code is covered by the MIT License, the full
+// text of which can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestStubTitle(t *testing.T) {
+	for _, data := range [...]struct {
+		name, expected string
+	}{
+		{"Widget", "Widget"},
+		{"gui::Window", "GuiWindow"},
+		{"gui::detail::window_impl", "GuiDetailWindowImpl"},
+		{"operator<<", "Operator"},
+	} {
+		compare(t, stubTitle(data.name), data.expected)
+	}
+}
+
+func TestWriteCoverageReport(t *testing.T) {
+	var out bytes.Buffer
+	writeCoverageReport(&out, "big", []coverageGroup{
+		{"class", "", 2, 1, []string{"Widget"}},
+		{"class", "gui", 1, 1, nil},
+		{"function", "gui", 4, 0, []string{"gui::draw"}},
+	})
+	compare(t, out.String(), "2 of 7 classes and functions in {big} have wiki documentation (28%).\n"+
+		"\n/*class*/\n\n"+
+		"    - {(global)}: 1 of 2 (50%)\n"+
+		"        - [doc:big:Widget] [CreateStub:../entitypage/big/Widget]\n"+
+		"    - {gui}: 1 of 1 (100%)\n"+
+		"\n/*function*/\n\n"+
+		"    - {gui}: 0 of 4 (0%)\n"+
+		"        - [doc:big:gui::draw] [CreateStub:../entitypage/big/gui::draw]\n")
+}
+
+func TestCoverageCommandUnknownProject(t *testing.T) {
+	var out bytes.Buffer
+	if status := coverageCommand(&out, "missing"); status != 2 {
+		t.Errorf("Expected status 2, got %d", status)
+	}
+}
@@ -0,0 +1,235 @@
+// Copyright (c) 2014, Daniel Gallagher
+// Use of this source code is covered by the MIT License, the full
+// text of which can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha1"
+	"fmt"
+	"github.com/danielgallagher0/docwiki/wikilang"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// compressibleTypes are the content types that are compressed when
+// the client accepts gzip and there is no precompressed file.
+var compressibleTypes = []string{"text/", "application/javascript", "application/json",
+	"application/xml", "image/svg+xml"}
+
+// fileHandler serves Doxygen HTML.  URLs are of the form
+// /doc/<project>/html/<file> or /doc/<project>@<version>/html/<file>,
+// and are served only from the HTML root configured for that version
+// of the project in projectIndex.xml.  Directories are not listed;
+// anything that is not a file in the HTML root gets a 404 page that
+// links back to the wiki.
+func fileHandler(w http.ResponseWriter, r *http.Request) {
+	parts := strings.SplitN(r.URL.Path[len(docPath):], "/", 3)
+	root, ok := wikilang.DocRoot(parts[0])
+	if !ok {
+		docNotFound(w, r, "")
+		return
+	}
+	if len(parts) < 3 || parts[1] != "html" {
+		docNotFound(w, r, parts[0])
+		return
+	}
+
+	name := path.Clean("/" + parts[2])
+	file := filepath.Join(root, filepath.FromSlash(name))
+	info, err := os.Stat(file)
+	if err == nil && info.IsDir() {
+		name = path.Join(name, "index.html")
+		file = filepath.Join(file, "index.html")
+		info, err = os.Stat(file)
+	}
+	if err != nil || info.IsDir() {
+		docNotFound(w, r, parts[0])
+		return
+	}
+
+	if path.Ext(name) == ".html" {
+		body, err := ioutil.ReadFile(file)
+		if err != nil {
+			docNotFound(w, r, parts[0])
+			return
+		}
+		serveDoc(w, r, name, info.ModTime(), addVersionSwitcher(body, parts[0], parts[2]), "")
+		return
+	}
+
+	if acceptsGzip(r) {
+		if gzipInfo, err := os.Stat(file + ".gz"); err == nil && !gzipInfo.ModTime().Before(info.ModTime()) {
+			if body, err := ioutil.ReadFile(file + ".gz"); err == nil {
+				serveDoc(w, r, name, info.ModTime(), body, "gzip")
+				return
+			}
+		}
+	}
+
+	body, err := ioutil.ReadFile(file)
+	if err != nil {
+		docNotFound(w, r, parts[0])
+		return
+	}
+	serveDoc(w, r, name, info.ModTime(), body, "")
+}
+
+// acceptsGzip reports whether the client accepts gzipped responses.
+func acceptsGzip(r *http.Request) bool {
+	for _, encoding := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		encoding = strings.TrimSpace(encoding)
+		if encoding == "gzip" || strings.HasPrefix(encoding, "gzip;") && !strings.HasSuffix(encoding, "q=0") {
+			return true
+		}
+	}
+	return false
+}
+
+// compressible reports whether a content type is worth compressing.
+func compressible(contentType string) bool {
+	for _, prefix := range compressibleTypes {
+		if strings.HasPrefix(contentType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// serveDoc serves the body of a Doxygen file, which is already
+// encoded if encoding is not empty.  Otherwise, it is gzipped if it
+// is text and the client accepts gzip.  The ETag is a hash of what
+// is sent, so it changes whenever the file or the version switcher
+// does.  http.ServeContent handles conditional and range requests.
+func serveDoc(w http.ResponseWriter, r *http.Request, name string, modTime time.Time, body []byte, encoding string) {
+	contentType := mime.TypeByExtension(path.Ext(name))
+	if contentType == "" {
+		contentType = http.DetectContentType(body)
+	}
+
+	if encoding == "" && compressible(contentType) && acceptsGzip(r) {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		gz.Write(body)
+		gz.Close()
+		body, encoding = buf.Bytes(), "gzip"
+	}
+
+	header := w.Header()
+	header.Set("Content-Type", contentType)
+	header.Set("Vary", "Accept-Encoding")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("ETag", fmt.Sprintf("\"%x\"", sha1.Sum(body)))
+	if encoding != "" {
+		header.Set("Content-Encoding", encoding)
+	}
+
+	http.ServeContent(w, r, name, modTime, bytes.NewReader(body))
+}
+
+// projectPage returns the title of the wiki page about a project:
+// the shortest title prefix that uses the project by default, or the
+// project's name made into a title.
+func projectPage(project string) string {
+	prefixes := []string{}
+	for prefix, p := range projectPrefixes {
+		if p == project {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	if len(prefixes) == 0 {
+		return stubTitle(project)
+	}
+
+	sort.Slice(prefixes, func(i, j int) bool {
+		if len(prefixes[i]) != len(prefixes[j]) {
+			return len(prefixes[i]) < len(prefixes[j])
+		}
+		return prefixes[i] < prefixes[j]
+	})
+	return prefixes[0]
+}
+
+// writeDocNotFound writes wiki text explaining that a file is not in
+// the Doxygen of a project, or that there is no such project if spec
+// is empty.
+func writeDocNotFound(w io.Writer, file, spec string) {
+	if spec == "" {
+		fmt.Fprintf(w, "There is no Doxygen at {%s}.\n\nSee [Projects:../projects] for the projects that have Doxygen.\n",
+			template.HTMLEscapeString(file))
+		return
+	}
+
+	project, _ := wikilang.SplitVersion(spec)
+	fmt.Fprintf(w, "{%s} is not in the Doxygen for %s.\n\n", template.HTMLEscapeString(file), project)
+	fmt.Fprintf(w, "See [%s] for more about %s, or [browse the Doxygen:../doc/%s/html/index.html] "+
+		"or [its entities:../projects/%s].\n", projectPage(project), project, spec, spec)
+}
+
+// docNotFound responds with a 404 page that explains why a Doxygen
+// file could not be found, and links to the project's documentation.
+// The page is rendered before anything is written, so that a template
+// error is a 500 rather than part of the 404.
+func docNotFound(w http.ResponseWriter, r *http.Request, spec string) {
+	var body bytes.Buffer
+	writeDocNotFound(&body, r.URL.Path, spec)
+
+	page, err := executeTemplate("report", &Page{Title: "NotFound", Body: body.Bytes()})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusNotFound)
+	w.Write(page)
+}
+
+// addVersionSwitcher adds links to the other versions of a project to
+// the top of a Doxygen HTML page.  Projects with only one version are
+// left alone.
+func addVersionSwitcher(body []byte, spec, file string) []byte {
+	project, current := wikilang.SplitVersion(spec)
+	versions, defaultVersion, ok := wikilang.DocVersions(project)
+	if !ok || len(versions) < 2 {
+		return body
+	}
+	if current == "" {
+		current = defaultVersion
+	}
+
+	var switcher bytes.Buffer
+	switcher.WriteString("<div class=\"docwiki-versions\">Version:")
+	for _, version := range versions {
+		if version == current {
+			fmt.Fprintf(&switcher, " <b>%s</b>", template.HTMLEscapeString(version))
+		} else {
+			fmt.Fprintf(&switcher, " <a href=\"%s%s%s/html/%s\">%s</a>",
+				proxyRoot(), docPath, wikilang.DocUrlName(project, version),
+				template.HTMLEscapeString(file), template.HTMLEscapeString(version))
+		}
+	}
+	switcher.WriteString("</div>")
+
+	insertAt := 0
+	if start := bytes.Index(body, []byte("<body")); start >= 0 {
+		if end := bytes.IndexByte(body[start:], '>'); end >= 0 {
+			insertAt = start + end + 1
+		}
+	}
+
+	result := append([]byte{}, body[:insertAt]...)
+	result = append(result, switcher.Bytes()...)
+	return append(result, body[insertAt:]...)
+}
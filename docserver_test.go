@@ -0,0 +1,95 @@
+// Copyright (c) 2014, Daniel Gallagher
+// Use of this source code is covered by the MIT License, the full
+// text of which can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServeDoc(t *testing.T) {
+	modTime := time.Date(2014, 5, 1, 12, 30, 0, 0, time.UTC)
+	body := []byte("body { color: black; }")
+
+	request := httptest.NewRequest("GET", "/doc/big/html/doxygen.css", nil)
+	response := httptest.NewRecorder()
+	serveDoc(response, request, "/doxygen.css", modTime, body, "")
+	if response.Code != http.StatusOK || response.Body.String() != string(body) {
+		t.Errorf("Unexpected response %d %q", response.Code, response.Body.String())
+	}
+	compare(t, response.Header().Get("Content-Type"), "text/css; charset=utf-8")
+	compare(t, response.Header().Get("Last-Modified"), "Thu, 01 May 2014 12:30:00 GMT")
+	etag := response.Header().Get("ETag")
+	if etag == "" {
+		t.Errorf("Expected an ETag")
+	}
+
+	request = httptest.NewRequest("GET", "/doc/big/html/doxygen.css", nil)
+	request.Header.Set("If-None-Match", etag)
+	response = httptest.NewRecorder()
+	serveDoc(response, request, "/doxygen.css", modTime, body, "")
+	if response.Code != http.StatusNotModified {
+		t.Errorf("Expected %d for a matching ETag, got %d", http.StatusNotModified, response.Code)
+	}
+
+	request = httptest.NewRequest("GET", "/doc/big/html/doxygen.css", nil)
+	request.Header.Set("Accept-Encoding", "deflate, gzip")
+	response = httptest.NewRecorder()
+	serveDoc(response, request, "/doxygen.css", modTime, body, "")
+	compare(t, response.Header().Get("Content-Encoding"), "gzip")
+	if response.Header().Get("ETag") == etag {
+		t.Errorf("Expected the gzipped ETag to differ")
+	}
+	reader, err := gzip.NewReader(response.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unzipped, _ := ioutil.ReadAll(reader)
+	compare(t, string(unzipped), string(body))
+
+	request = httptest.NewRequest("GET", "/doc/big/html/logo.png", nil)
+	request.Header.Set("Accept-Encoding", "gzip")
+	response = httptest.NewRecorder()
+	serveDoc(response, request, "/logo.png", modTime, []byte("\x89PNG"), "")
+	compare(t, response.Header().Get("Content-Encoding"), "")
+}
+
+func TestDocNotFound(t *testing.T) {
+	request := httptest.NewRequest("GET", "/doc/missing/html/index.html", nil)
+	response := httptest.NewRecorder()
+	fileHandler(response, request)
+	if response.Code != http.StatusNotFound {
+		t.Errorf("Expected %d for a missing project, got %d", http.StatusNotFound, response.Code)
+	}
+	if body := response.Body.String(); !strings.Contains(body, "missing") || strings.Contains(body, "Internal Server Error") {
+		t.Errorf("Unexpected body for a missing project:\n%s", body)
+	}
+
+	var out bytes.Buffer
+	writeDocNotFound(&out, "/doc/missing/<b>", "")
+	compare(t, out.String(), "There is no Doxygen at {/doc/missing/&lt;b&gt;}.\n\n"+
+		"See [Projects:../projects] for the projects that have Doxygen.\n")
+
+	SetProjectPrefixes(map[string]string{"Big": "bigproject", "BigProject": "bigproject"})
+	defer SetProjectPrefixes(nil)
+
+	out.Reset()
+	writeDocNotFound(&out, "/doc/bigproject@1.2/html/gone.html", "bigproject@1.2")
+	compare(t, out.String(), "{/doc/bigproject@1.2/html/gone.html} is not in the Doxygen for bigproject.\n\n"+
+		"See [Big] for more about bigproject, or [browse the Doxygen:../doc/bigproject@1.2/html/index.html] "+
+		"or [its entities:../projects/bigproject@1.2].\n")
+
+	out.Reset()
+	writeDocNotFound(&out, "/doc/small/html/gone.html", "small")
+	compare(t, out.String(), "{/doc/small/html/gone.html} is not in the Doxygen for small.\n\n"+
+		"See [Small] for more about small, or [browse the Doxygen:../doc/small/html/index.html] "+
+		"or [its entities:../projects/small].\n")
+}
@@ -0,0 +1,103 @@
+// Copyright (c) 2014, Daniel Gallagher
+// Use of this source code is covered by the MIT License, the full
+// text of which can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"github.com/danielgallagher0/docwiki/wikilang"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const entityPagePath = "/entitypage/"
+
+// entityParam is the query parameter that asks editHandler to fill a
+// new page with a skeleton for a Doxygen entity.
+const entityParam = "entity"
+
+// entityPage finds the page associated with an entity by an #entity
+// metadata line, as in "#entity project:Namespace::Class".
+func entityPage(project, name string) (string, bool) {
+	association := project + ":" + name
+	for _, entry := range indexedPages() {
+		if entry.entity != association {
+			continue
+		}
+		if _, ok := indexedPage(entry.title); ok {
+			return entry.title, true
+		}
+	}
+
+	return "", false
+}
+
+// newEntityPageTitle returns a title for a new page about an entity
+// that no page has yet.  The title is made from the entity's name, or
+// if a page already has that title, from the project and the name.
+func newEntityPageTitle(project, name string) string {
+	title := stubTitle(name)
+	if _, ok := indexedPage(title); !ok {
+		return title
+	}
+
+	title = stubTitle(project + " " + name)
+	next := title
+	for i := 2; ; i++ {
+		if _, ok := indexedPage(next); !ok {
+			return next
+		}
+		next = fmt.Sprintf("%s%d", title, i)
+	}
+}
+
+// entitySkeleton generates the initial text for a page about an
+// entity.  The #entity line records the page's association with the
+// entity when the page is saved, and the #title line titles the page
+// with the entity's name.
+func entitySkeleton(association string) []byte {
+	parts := strings.SplitN(association, ":", 2)
+	if len(parts) != 2 {
+		return nil
+	}
+
+	brief := ""
+	if e, ok := wikilang.LookupEntity(parts[0], parts[1]); ok && e.Brief != "" {
+		brief = " " + e.Brief
+	}
+
+	return []byte(fmt.Sprintf("#entity %s\n"+
+		"#title %s\n"+
+		"[doc:%s]%s\n\n"+
+		"/*Design Rationale*/\n\n"+
+		"/*Gotchas*/\n", association, parts[1], association, brief))
+}
+
+// entityPageHandler shows the wiki page for a Doxygen entity at
+// /entitypage/<project>/<entity>.  If no page's #entity names the
+// entity, it opens the editor on a new page for the entity, as long as
+// the entity is in the project's Doxygen.
+func entityPageHandler(w http.ResponseWriter, r *http.Request) {
+	parts := strings.SplitN(r.URL.Path[len(entityPagePath):], "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		http.NotFound(w, r)
+		return
+	}
+	project, name := parts[0], parts[1]
+
+	if title, ok := entityPage(project, name); ok {
+		http.Redirect(w, r, proxyRoot()+viewPath+title, http.StatusFound)
+		return
+	}
+
+	if _, ok := wikilang.LookupEntity(project, name); !ok {
+		docNotFound(w, r, project)
+		return
+	}
+
+	title := newEntityPageTitle(project, name)
+	http.Redirect(w, r, proxyRoot()+editPath+title+"?"+entityParam+"="+
+		url.QueryEscape(project+":"+name), http.StatusFound)
+}
@@ -0,0 +1,73 @@
+// Copyright (c) 2014, Daniel Gallagher
+// Use of this source code is covered by the MIT License, the full
+// text of which can be found in the LICENSE file.
+
+package main
+
+import (
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestEntitySkeleton(t *testing.T) {
+	compare(t, string(entitySkeleton("")), "")
+	compare(t, string(entitySkeleton("big:gui::Window")), "#entity big:gui::Window\n"+
+		"#title gui::Window\n"+
+		"[doc:big:gui::Window]\n\n"+
+		"/*Design Rationale*/\n\n"+
+		"/*Gotchas*/\n")
+}
+
+func TestEntityPage(t *testing.T) {
+	pages := map[string]string{
+		"EntityPageTest": "#entity big:gui::Window\nText",
+		"GuiWindow":      "Not about big:gui::Window",
+		"BigGuiWindow":   "#entity other:gui::Window\nText",
+	}
+	for title, body := range pages {
+		(&Page{Title: title, Body: []byte(body)}).save()
+	}
+	defer func() {
+		for title := range pages {
+			os.Remove(pageFile(title))
+		}
+	}()
+
+	title, ok := entityPage("big", "gui::Window")
+	if !ok {
+		t.Fatalf("Expected a page for big:gui::Window")
+	}
+	compare(t, title, "EntityPageTest")
+	if title, ok := entityPage("small", "gui::Window"); ok {
+		t.Errorf("Expected no page for small:gui::Window, got %s", title)
+	}
+
+	compare(t, newEntityPageTitle("big", "gui::Button"), "GuiButton")
+	compare(t, newEntityPageTitle("big", "gui::Window"), "BigGuiWindow2")
+}
+
+func TestEntityPageHandler(t *testing.T) {
+	(&Page{Title: "EntityPageTest", Body: []byte("#entity big:gui::Window\nText")}).save()
+	defer os.Remove(pageFile("EntityPageTest"))
+
+	for _, data := range [...]struct {
+		path, location string
+	}{
+		{"/entitypage/big/gui::Window", "/view/EntityPageTest"},
+	} {
+		w := httptest.NewRecorder()
+		entityPageHandler(w, httptest.NewRequest("GET", data.path, nil))
+		compare(t, w.Header().Get("Location"), data.location)
+	}
+
+	// Entities that are not in the project's Doxygen do not get pages.
+	for _, path := range []string{"/entitypage/big", "/entitypage/big/gui::Wndow", "/entitypage/big/DocWiki"} {
+		w := httptest.NewRecorder()
+		entityPageHandler(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != 404 {
+			t.Errorf("Expected status 404 for %s, got %d", path, w.Code)
+		}
+		compare(t, w.Header().Get("Location"), "")
+	}
+}
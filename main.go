@@ -7,15 +7,22 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"github.com/danielgallagher0/docwiki/wikilang"
 	"io/ioutil"
+	"os"
+	"time"
 )
 
 const confFile = "docwiki.conf"
 
 func main() {
 	type Config struct {
-		Port      int
-		ProxyRoot string
+		Port            int
+		ProxyRoot       string
+		ProjectPrefixes map[string]string
+		AutoLink        bool
+		TrashDays       int
+		Interwiki       map[string]string
 	}
 
 	data, err := ioutil.ReadFile(confFile)
@@ -29,5 +36,28 @@ func main() {
 	}
 
 	SetProxyRoot(conf.ProxyRoot)
+	SetProjectPrefixes(conf.ProjectPrefixes)
+	SetAutoLink(conf.AutoLink)
+	wikilang.SetInterwiki(conf.Interwiki)
+	if conf.TrashDays != 0 {
+		SetTrashRetention(time.Duration(conf.TrashDays) * 24 * time.Hour)
+	}
+
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "check":
+			os.Exit(checkCommand(os.Stdout))
+		case "coverage":
+			if len(os.Args) < 3 {
+				fmt.Fprintf(os.Stderr, "Usage: %s coverage <project>\n", os.Args[0])
+				os.Exit(2)
+			}
+			os.Exit(coverageCommand(os.Stdout, os.Args[2]))
+		default:
+			fmt.Fprintf(os.Stderr, "Unknown command %s\n", os.Args[1])
+			os.Exit(2)
+		}
+	}
+
 	ListenAndServe(conf.Port)
 }
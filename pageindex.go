@@ -0,0 +1,129 @@
+// Copyright (c) 2014, Daniel Gallagher
+// Use of this source code is covered by the MIT License, the full
+// text of which can be found in the LICENSE file.
+
+package main
+
+import (
+	"github.com/danielgallagher0/docwiki/wikilang"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// A pageEntry is what the page index knows about a page.
+type pageEntry struct {
+	title    string   // The page's title, as it is stored
+	display  string   // The page's #title, if it has one
+	redirect string   // The page the page's #redirect goes to, if any
+	aliases  []string // The page's #alias titles
+	entity   string   // The entity named by the page's #entity, if any
+}
+
+// pageIndex caches every page's title and metadata, so that titles
+// can be matched to pages without regard to case, and links can show
+// display titles, follow redirects and aliases, and find the pages
+// about entities, without reading dataDir.  It is loaded the first time it is used, and kept up to
+// date by save and removePage.  Pages added to dataDir by hand are not
+// in it until the wiki restarts.
+var pageIndex struct {
+	sync.RWMutex
+	pages map[string]pageEntry // Keyed by indexKey
+}
+
+// indexKey returns the key of a title in the page index.  All of the
+// spellings of a title that name the same page have the same key.
+func indexKey(title string) string {
+	return strings.ToLower(wikilang.CanonicalTitle(title))
+}
+
+func newPageEntry(p *Page) pageEntry {
+	view := p.view()
+	return pageEntry{view.Title, view.Meta["title"], view.redirect(), view.aliases(), view.Meta["entity"]}
+}
+
+// loadPageIndex reads every page into the page index, if it has not
+// been loaded yet.
+func loadPageIndex() {
+	pageIndex.Lock()
+	defer pageIndex.Unlock()
+	if pageIndex.pages != nil {
+		return
+	}
+
+	pages := map[string]pageEntry{}
+	for _, title := range listPages() {
+		if body, err := ioutil.ReadFile(pageFile(title)); err == nil {
+			pages[indexKey(title)] = newPageEntry(&Page{Title: title, Body: body})
+		}
+	}
+	pageIndex.pages = pages
+}
+
+// indexedPage returns the page index's entry for the page that a
+// title names, matched without regard to case.  Pages whose files have
+// gone are dropped from the index.
+func indexedPage(title string) (pageEntry, bool) {
+	loadPageIndex()
+
+	pageIndex.RLock()
+	entry, ok := pageIndex.pages[indexKey(title)]
+	pageIndex.RUnlock()
+
+	if ok {
+		if _, err := os.Stat(pageFile(entry.title)); err != nil {
+			unindexPage(entry.title)
+			return pageEntry{}, false
+		}
+	}
+	return entry, ok
+}
+
+// indexedPages returns the page index's entries for every page,
+// sorted by title.
+func indexedPages() []pageEntry {
+	loadPageIndex()
+
+	pageIndex.RLock()
+	entries := []pageEntry{}
+	for _, entry := range pageIndex.pages {
+		entries = append(entries, entry)
+	}
+	pageIndex.RUnlock()
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].title < entries[j].title
+	})
+	return entries
+}
+
+// indexPage adds a page that has been saved to the page index.
+func indexPage(p *Page) {
+	entry := newPageEntry(p)
+
+	pageIndex.Lock()
+	defer pageIndex.Unlock()
+	if pageIndex.pages != nil {
+		pageIndex.pages[indexKey(p.Title)] = entry
+	}
+}
+
+// unindexPage removes a page that has been removed from the page
+// index.
+func unindexPage(title string) {
+	pageIndex.Lock()
+	defer pageIndex.Unlock()
+	delete(pageIndex.pages, indexKey(title))
+}
+
+// removePage removes a page's file, and removes it from the page
+// index.
+func removePage(title string) error {
+	if err := os.Remove(pageFile(title)); err != nil {
+		return err
+	}
+	unindexPage(title)
+	return nil
+}
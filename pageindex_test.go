@@ -0,0 +1,34 @@
+// Copyright (c) 2014, Daniel Gallagher
+// Use of this source code is covered by the MIT License, the full
+// text of which can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestPageIndex(t *testing.T) {
+	p := &Page{Title: "page index test", Body: []byte("Text")}
+	if err := p.save(); err != nil {
+		t.Fatal(err)
+	}
+	compare(t, canonicalTitle("PAGE INDEX TEST"), "PageIndexTest")
+
+	if err := removePage("PageIndexTest"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := indexedPage("PageIndexTest"); ok {
+		t.Errorf("Expected a removed page to leave the index")
+	}
+
+	// Pages removed behind the wiki's back are dropped when they are
+	// looked up.
+	(&Page{Title: "PageIndexTest", Body: []byte("Text")}).save()
+	os.Remove(pageFile("PageIndexTest"))
+	if _, ok := indexedPage("page index test"); ok {
+		t.Errorf("Expected a missing page to leave the index")
+	}
+	compare(t, canonicalTitle("page index test"), "PageIndexTest")
+}
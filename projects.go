@@ -0,0 +1,192 @@
+// Copyright (c) 2014, Daniel Gallagher
+// Use of this source code is covered by the MIT License, the full
+// text of which can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/danielgallagher0/docwiki/wikilang"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const projectsPath = "/projects"
+const projectPath = "/projects/"
+
+// browseLimit is the most entities that a project's browser shows at
+// once.  Larger projects need to be filtered.
+const browseLimit = 1000
+
+// versionLabel names one version of a project for the project list.
+func versionLabel(status wikilang.IndexStatus) string {
+	if status.Version == "" {
+		return status.Project
+	}
+	return status.Project + wikilang.VersionSeparator + status.Version
+}
+
+// writeProjectList writes wiki text listing every project, with the
+// status of each of its versions.
+func writeProjectList(w io.Writer, projects []string, statuses map[string][]wikilang.IndexStatus) {
+	fmt.Fprintf(w, "%d projects are defined in {projectIndex.xml}.\n", len(projects))
+
+	for _, project := range projects {
+		fmt.Fprintf(w, "\n/*%s*/\n\n", project)
+		fmt.Fprintf(w, "[Coverage:../admin/coverage/%s] [Changes:../doc-changes/%s]\n\n", project, project)
+
+		for _, status := range statuses[project] {
+			spec := versionLabel(status)
+			fmt.Fprintf(w, "    - [%s:../projects/%s]", spec, spec)
+			if status.Default && len(statuses[project]) > 1 {
+				fmt.Fprintf(w, " (default)")
+			}
+
+			switch status.State {
+			case wikilang.IndexLoading:
+				fmt.Fprintf(w, ": %s\n", status.State)
+			case wikilang.IndexFailed:
+				fmt.Fprintf(w, ": %s: %s\n", status.State, status.Error)
+			default:
+				fmt.Fprintf(w, ": %s, %d entities, loaded in %v at %s", status.State, status.Entities,
+					status.LoadTime.Round(time.Millisecond), status.LoadedAt.Format("2006-01-02 15:04:05"))
+				if status.Error != "" {
+					fmt.Fprintf(w, " (%s)", status.Error)
+				}
+				fmt.Fprintf(w, "\n")
+			}
+		}
+	}
+}
+
+func projectsHandler(w http.ResponseWriter, r *http.Request) {
+	projects := wikilang.Projects()
+	statuses := map[string][]wikilang.IndexStatus{}
+	for _, project := range projects {
+		statuses[project] = wikilang.Status(project)
+	}
+
+	var body bytes.Buffer
+	writeProjectList(&body, projects, statuses)
+	renderTemplate(w, "report", &Page{Title: "Projects", Body: body.Bytes()})
+}
+
+// browseEntities returns the entities of the given kind, or of all
+// kinds if kind is empty, whose names contain filter, ignoring case.
+// The entities are sorted by kind, then alphabetically by name.  It
+// also returns the number of matching entities of each kind,
+// regardless of the kind asked for.
+func browseEntities(entities []wikilang.Entity, kind, filter string) ([]wikilang.Entity, map[string]int) {
+	filter = strings.ToLower(filter)
+
+	matches := []wikilang.Entity{}
+	counts := map[string]int{}
+	for _, e := range entities {
+		if !strings.Contains(strings.ToLower(e.Name), filter) {
+			continue
+		}
+
+		counts[e.Kind]++
+		if kind == "" || e.Kind == kind {
+			matches = append(matches, e)
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Kind != matches[j].Kind {
+			return matches[i].Kind < matches[j].Kind
+		}
+		left, right := strings.ToLower(matches[i].Name), strings.ToLower(matches[j].Name)
+		if left != right {
+			return left < right
+		}
+		return matches[i].Name < matches[j].Name
+	})
+
+	return matches, counts
+}
+
+// browseQuery builds the query string for a project browser link.
+func browseQuery(kind, filter string) string {
+	query := url.Values{}
+	if kind != "" {
+		query.Set("kind", kind)
+	}
+	if filter != "" {
+		query.Set("filter", filter)
+	}
+	return "?" + query.Encode()
+}
+
+// writeProjectBrowser writes wiki text for browsing the entities of
+// the project specification spec, showing at most limit of them.
+func writeProjectBrowser(w io.Writer, spec string, entities []wikilang.Entity, kind, filter string, limit int) {
+	matches, counts := browseEntities(entities, kind, filter)
+
+	fmt.Fprintf(w, "<form method=\"GET\"><input type=\"text\" name=\"filter\" value=\"%s\" placeholder=\"Filter\" />"+
+		" <input type=\"hidden\" name=\"kind\" value=\"%s\" /> <input type=\"submit\" value=\"Filter\" /></form>\n\n",
+		html.EscapeString(filter), html.EscapeString(kind))
+
+	kinds := []string{}
+	total := 0
+	for k, count := range counts {
+		kinds = append(kinds, k)
+		total += count
+	}
+	sort.Strings(kinds)
+
+	fmt.Fprintf(w, "[all:%s] (%d)", browseQuery("", filter), total)
+	for _, k := range kinds {
+		fmt.Fprintf(w, " [%s:%s] (%d)", k, browseQuery(k, filter), counts[k])
+	}
+	fmt.Fprintf(w, "\n")
+
+	if len(matches) > limit {
+		fmt.Fprintf(w, "\nShowing the first %d of %d entities.  Use the filter to narrow them down.\n", limit, len(matches))
+		matches = matches[:limit]
+	}
+
+	current := ""
+	for _, e := range matches {
+		if e.Kind != current {
+			current = e.Kind
+			fmt.Fprintf(w, "\n/*%s*/\n\n", current)
+		}
+		fmt.Fprintf(w, "    - [doc:%s:%s]\n", spec, e.Name)
+	}
+}
+
+// indexStatus returns the status of the version of a project named by
+// spec.
+func indexStatus(spec string) (wikilang.IndexStatus, bool) {
+	project, version := wikilang.SplitVersion(spec)
+	for _, status := range wikilang.Status(project) {
+		if status.Version == version || (version == "" && status.Default) {
+			return status, true
+		}
+	}
+	return wikilang.IndexStatus{}, false
+}
+
+func projectHandler(w http.ResponseWriter, r *http.Request) {
+	spec := r.URL.Path[len(projectPath):]
+	status, ok := indexStatus(spec)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	var body bytes.Buffer
+	if status.State == wikilang.IndexLoading {
+		fmt.Fprintf(&body, "{%s} is still being indexed.  Try again shortly.\n", spec)
+	} else {
+		writeProjectBrowser(&body, spec, wikilang.Entities(spec), r.FormValue("kind"), r.FormValue("filter"), browseLimit)
+	}
+	renderTemplate(w, "report", &Page{Title: spec, Body: body.Bytes()})
+}
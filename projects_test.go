@@ -0,0 +1,76 @@
+// Copyright (c) 2014, Daniel Gallagher
+// Use of this source code is covered by the MIT License, the full
+// text of which can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"github.com/danielgallagher0/docwiki/wikilang"
+	"testing"
+	"time"
+)
+
+func TestWriteProjectList(t *testing.T) {
+	loaded := time.Date(2014, 5, 1, 12, 30, 0, 0, time.UTC)
+
+	var out bytes.Buffer
+	writeProjectList(&out, []string{"big", "small"}, map[string][]wikilang.IndexStatus{
+		"big": {
+			{Project: "big", Version: "1.0", State: wikilang.IndexFailed, Error: "no such file"},
+			{Project: "big", Version: "1.1", Default: true, State: wikilang.IndexReady, Entities: 12,
+				LoadTime: 1500 * time.Microsecond, LoadedAt: loaded},
+		},
+		"small": {
+			{Project: "small", Default: true, State: wikilang.IndexLoading},
+		},
+	})
+	compare(t, out.String(), "2 projects are defined in {projectIndex.xml}.\n"+
+		"\n/*big*/\n\n"+
+		"[Coverage:../admin/coverage/big] [Changes:../doc-changes/big]\n\n"+
+		"    - [big@1.0:../projects/big@1.0]: failed: no such file\n"+
+		"    - [big@1.1:../projects/big@1.1] (default): ready, 12 entities, loaded in 2ms at 2014-05-01 12:30:00\n"+
+		"\n/*small*/\n\n"+
+		"[Coverage:../admin/coverage/small] [Changes:../doc-changes/small]\n\n"+
+		"    - [small:../projects/small]: loading\n")
+}
+
+func TestWriteProjectBrowser(t *testing.T) {
+	entities := []wikilang.Entity{
+		{Name: "gui::Window", Kind: "class"},
+		{Name: "Widget", Kind: "class"},
+		{Name: "gui::Window::widget", Kind: "function"},
+		{Name: "draw", Kind: "function"},
+		{Name: "apple", Kind: "class"},
+	}
+
+	var out bytes.Buffer
+	writeProjectBrowser(&out, "big", entities, "", "", 10)
+	compare(t, out.String(), "<form method=\"GET\"><input type=\"text\" name=\"filter\" value=\"\" placeholder=\"Filter\" />"+
+		" <input type=\"hidden\" name=\"kind\" value=\"\" /> <input type=\"submit\" value=\"Filter\" /></form>\n\n"+
+		"[all:?] (5) [class:?kind=class] (3) [function:?kind=function] (2)\n"+
+		"\n/*class*/\n\n"+
+		"    - [doc:big:apple]\n"+
+		"    - [doc:big:gui::Window]\n"+
+		"    - [doc:big:Widget]\n"+
+		"\n/*function*/\n\n"+
+		"    - [doc:big:draw]\n"+
+		"    - [doc:big:gui::Window::widget]\n")
+
+	out.Reset()
+	writeProjectBrowser(&out, "big", entities, "class", "WID", 10)
+	compare(t, out.String(), "<form method=\"GET\"><input type=\"text\" name=\"filter\" value=\"WID\" placeholder=\"Filter\" />"+
+		" <input type=\"hidden\" name=\"kind\" value=\"class\" /> <input type=\"submit\" value=\"Filter\" /></form>\n\n"+
+		"[all:?filter=WID] (2) [class:?filter=WID&kind=class] (1) [function:?filter=WID&kind=function] (1)\n"+
+		"\n/*class*/\n\n"+
+		"    - [doc:big:Widget]\n")
+
+	out.Reset()
+	writeProjectBrowser(&out, "big", entities, "class", "", 1)
+	compare(t, out.String(), "<form method=\"GET\"><input type=\"text\" name=\"filter\" value=\"\" placeholder=\"Filter\" />"+
+		" <input type=\"hidden\" name=\"kind\" value=\"class\" /> <input type=\"submit\" value=\"Filter\" /></form>\n\n"+
+		"[all:?] (5) [class:?kind=class] (3) [function:?kind=function] (2)\n"+
+		"\nShowing the first 1 of 3 entities.  Use the filter to narrow them down.\n"+
+		"\n/*class*/\n\n"+
+		"    - [doc:big:apple]\n")
+}
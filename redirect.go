@@ -0,0 +1,113 @@
+// Copyright (c) 2014, Daniel Gallagher
+// Use of this source code is covered by the MIT License, the full
+// text of which can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"github.com/danielgallagher0/docwiki/wikilang"
+	"strings"
+)
+
+// maxRedirects is the longest chain of redirects that is followed.
+// Longer chains are treated as loops.
+const maxRedirects = 10
+
+// metaTitle returns the title named by a metadata value, which may be
+// written as a wikilink or as a bare title.  Relative titles are
+// resolved from the page the metadata is on.
+func metaTitle(page, value string) string {
+	title := strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(value), "["), "]"))
+	if title == "" {
+		return ""
+	}
+	return wikilang.CanonicalTitle(wikilang.ResolveTitle(page, title))
+}
+
+// redirect returns the title that a page redirects to with a
+// "#redirect [Title]" line at the top, if it has one.
+func (p *Page) redirect() string {
+	return metaTitle(p.Title, p.Meta["redirect"])
+}
+
+// aliases returns the other titles of a page, given by an
+// "#alias Title, Other Title" line at the top.
+func (p *Page) aliases() []string {
+	aliases := []string{}
+	for _, value := range strings.Split(p.Meta["alias"], ",") {
+		if alias := metaTitle(p.Title, value); alias != "" {
+			aliases = append(aliases, alias)
+		}
+	}
+	return aliases
+}
+
+// aliasOf returns the page that has title as an alias.  Pages are
+// only looked up by alias if there is no page with the title itself.
+func aliasOf(title string) (string, bool) {
+	for _, entry := range indexedPages() {
+		for _, alias := range entry.aliases {
+			if strings.EqualFold(alias, title) {
+				if _, ok := indexedPage(entry.title); ok {
+					return entry.title, true
+				}
+			}
+		}
+	}
+	return "", false
+}
+
+// nextTitle returns the title that a request for title is sent to,
+// either by the page's #redirect line or because it is another page's
+// alias.
+func nextTitle(title string) (string, bool) {
+	entry, ok := indexedPage(title)
+	if !ok {
+		return aliasOf(title)
+	}
+	return entry.redirect, entry.redirect != ""
+}
+
+// followRedirects returns the title of the page that a request for
+// title ends up at, after following every redirect and alias.  It
+// returns an error if the redirects go around in a loop.
+func followRedirects(title string) (string, error) {
+	title = canonicalTitle(title)
+	chain := []string{title}
+	for {
+		next, ok := nextTitle(title)
+		if !ok {
+			return title, nil
+		}
+
+		next = canonicalTitle(next)
+		for _, seen := range chain {
+			if strings.EqualFold(seen, next) || len(chain) > maxRedirects {
+				return "", fmt.Errorf("redirect loop: %s -> %s", strings.Join(chain, " -> "), next)
+			}
+		}
+		chain = append(chain, next)
+		title = next
+	}
+}
+
+// redirectsTo returns the titles of every page that redirects to
+// title, directly or through other redirects, along with its aliases
+// and title itself.
+func redirectsTo(title string) []string {
+	names := []string{title}
+	if entry, ok := indexedPage(title); ok {
+		names = append(names, entry.aliases...)
+	}
+
+	for _, entry := range indexedPages() {
+		if entry.redirect == "" || strings.EqualFold(entry.title, title) {
+			continue
+		}
+		if target, err := followRedirects(entry.title); err == nil && strings.EqualFold(target, title) {
+			names = append(names, entry.title)
+		}
+	}
+	return names
+}
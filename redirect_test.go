@@ -0,0 +1,106 @@
+// Copyright (c) 2014, Daniel Gallagher
+// Use of this source code is covered by the MIT License, the full
+// text of which can be found in the LICENSE file.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestRedirects(t *testing.T) {
+	pages := map[string]string{
+		"RedirectTestTarget":   "#alias Redirect Test Alias, RedirectTestOtherName\nThe real page.",
+		"RedirectTestOld":      "#redirect [RedirectTestMiddle]\n",
+		"RedirectTestMiddle":   "#redirect RedirectTestTarget\n",
+		"RedirectTestLoopA":    "#redirect [RedirectTestLoopB]\n",
+		"RedirectTestLoopB":    "#redirect [redirect test loop a]\n",
+		"RedirectTestReferrer": "See [RedirectTestOld].",
+		"RedirectTestAliaser":  "See [RedirectTestOtherName].",
+		"RedirectTestDirect":   "See [RedirectTestTarget].",
+		"RedirectTestNone":     "See [RedirectTestLoopA].",
+	}
+	for title, body := range pages {
+		(&Page{Title: title, Body: []byte(body)}).save()
+	}
+	defer func() {
+		for title := range pages {
+			os.Remove(pageFile(title))
+		}
+	}()
+
+	for _, data := range []struct {
+		title, expected string
+	}{
+		{"RedirectTestTarget", "RedirectTestTarget"},
+		{"RedirectTestOld", "RedirectTestTarget"},
+		{"RedirectTestAlias", "RedirectTestTarget"},
+		{"RedirectTestNowhere", "RedirectTestNowhere"},
+	} {
+		actual, err := followRedirects(data.title)
+		if err != nil {
+			t.Errorf("Unexpected error following %s: %s", data.title, err)
+		}
+		compare(t, actual, data.expected)
+	}
+
+	if _, err := followRedirects("RedirectTestLoopA"); err == nil {
+		t.Errorf("Expected a redirect loop")
+	}
+
+	compare(t, strings.Join(redirectsTo("RedirectTestTarget"), " "),
+		"RedirectTestTarget RedirectTestAlias RedirectTestOtherName RedirectTestMiddle RedirectTestOld")
+
+	handler := makeHandler(viewHandler, viewPath)
+	for _, data := range []struct {
+		path     string
+		code     int
+		location string
+		contains string
+	}{
+		{"/view/RedirectTestOld", http.StatusFound, "/view/RedirectTestTarget?from=RedirectTestOld", ""},
+		{"/view/RedirectTestAlias", http.StatusFound, "/view/RedirectTestTarget?from=RedirectTestAlias", ""},
+		{"/view/RedirectTestOld?redirect=no", http.StatusOK, "", "Redirect Test Old"},
+		{"/view/RedirectTestTarget?from=RedirectTestOld", http.StatusOK, "", "Redirected from"},
+		{"/view/RedirectTestLoopA", http.StatusOK, "", "redirects in a loop"},
+	} {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest("GET", data.path, nil))
+		if w.Code != data.code {
+			t.Errorf("Expected %d for %s, got %d", data.code, data.path, w.Code)
+		}
+		compare(t, w.Header().Get("Location"), data.location)
+		if !strings.Contains(w.Body.String(), data.contains) {
+			t.Errorf("Expected %s to contain %q:\n%s", data.path, data.contains, w.Body.String())
+		}
+	}
+
+	w := httptest.NewRecorder()
+	makeHandler(searchHandler, searchPath)(w, httptest.NewRequest("GET", "/search/RedirectTestTarget", nil))
+	for _, title := range []string{"RedirectTestReferrer", "RedirectTestAliaser", "RedirectTestDirect"} {
+		if !strings.Contains(w.Body.String(), "/view/"+title+"\"") {
+			t.Errorf("Expected a backlink from %s:\n%s", title, w.Body.String())
+		}
+	}
+	if strings.Contains(w.Body.String(), "/view/RedirectTestNone\"") {
+		t.Errorf("Unexpected backlink from RedirectTestNone:\n%s", w.Body.String())
+	}
+
+	// Saving a page changes where it redirects to straight away.
+	(&Page{Title: "RedirectTestMiddle", Body: []byte("#alias RedirectTestNewAlias\nNo longer a redirect.")}).save()
+	for _, data := range []struct {
+		title, expected string
+	}{
+		{"RedirectTestOld", "RedirectTestMiddle"},
+		{"RedirectTestNewAlias", "RedirectTestMiddle"},
+	} {
+		actual, _ := followRedirects(data.title)
+		compare(t, actual, data.expected)
+	}
+	compare(t, strings.Join(redirectsTo("RedirectTestTarget"), " "),
+		"RedirectTestTarget RedirectTestAlias RedirectTestOtherName")
+}
@@ -0,0 +1,202 @@
+// Copyright (c) 2014, Daniel Gallagher
+// Use of this source code is covered by the MIT License, the full
+// text of which can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"github.com/danielgallagher0/docwiki/wikilang"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const renamePath = "/rename/"
+
+// A pageMove is a page that a rename moves from one title to another.
+type pageMove struct {
+	from, to string
+}
+
+// renamePage moves a page, and the pages under it, to a new title and
+// rewrites the wikilinks to them in every page, including the pages
+// themselves.  If redirect is set, a page is left at each old title
+// that redirects to the new one.  Titles that differ only in case
+// name the same page, so renaming Frontpage to FrontPage only changes
+// the case.  It returns the titles of the pages whose links were
+// rewritten.  If a page cannot be moved, the pages already moved are
+// moved back.
+func renamePage(oldTitle, newTitle string, redirect bool) ([]string, error) {
+	p, err := loadPage(oldTitle)
+	if err != nil {
+		return nil, fmt.Errorf("%s does not exist", oldTitle)
+	}
+
+	oldTitle = p.Title
+	newTitle = wikilang.CanonicalTitle(newTitle)
+	if strings.HasPrefix(strings.ToLower(newTitle), strings.ToLower(oldTitle)+"/") {
+		return nil, fmt.Errorf("%s cannot be moved under itself", oldTitle)
+	}
+
+	moves := []pageMove{{oldTitle, newTitle}}
+	for _, title := range listPages() {
+		if strings.HasPrefix(title, oldTitle+"/") {
+			moves = append(moves, pageMove{title, newTitle + title[len(oldTitle):]})
+		}
+	}
+	sort.Slice(moves, func(i, j int) bool {
+		return moves[i].from < moves[j].from
+	})
+
+	caseOnly := strings.EqualFold(oldTitle, newTitle)
+	pages := []*Page{}
+	for _, move := range moves {
+		page, err := loadPage(move.from)
+		if err != nil {
+			return nil, err
+		}
+		pages = append(pages, page)
+
+		if caseOnly {
+			continue
+		}
+		if _, err := os.Stat(pageFile(move.to)); err == nil {
+			return nil, fmt.Errorf("%s already exists", move.to)
+		}
+		if entry, ok := indexedPage(move.to); ok {
+			return nil, fmt.Errorf("%s already exists", entry.title)
+		}
+	}
+
+	stubs := redirect && !caseOnly
+	for i, move := range moves {
+		if err := movePage(pages[i], move.to); err != nil {
+			return nil, undoMoves(moves[:i], pages[:i], stubs, err)
+		}
+
+		if stubs {
+			stub := &Page{Title: move.from, Body: []byte(fmt.Sprintf("#redirect [%s]\n", move.to))}
+			if err := stub.save(); err != nil {
+				return nil, undoMoves(moves[:i+1], pages[:i+1], stubs, err)
+			}
+		}
+	}
+	removeEmptyDirs(strings.TrimSuffix(pageFile(oldTitle), ".txt"))
+
+	changed := []string{}
+	for _, title := range listPages() {
+		page, err := loadPage(title)
+		if err != nil {
+			continue
+		}
+
+		body := string(page.Body)
+		count := 0
+		for _, move := range moves {
+			var n int
+			body, n = wikilang.RenameLinks(body, move.from, move.to, page.view().renderOptions())
+			count += n
+		}
+		if count == 0 {
+			continue
+		}
+
+		page.Body = []byte(body)
+		if err := page.save(); err != nil {
+			return changed, err
+		}
+		changed = append(changed, title)
+	}
+	sort.Strings(changed)
+
+	return changed, nil
+}
+
+// undoMoves moves pages back to their old titles after a rename fails
+// partway, and removes the redirects left at the old titles.  It
+// returns the error that stopped the rename, along with the titles of
+// any pages that could not be moved back.
+func undoMoves(moves []pageMove, pages []*Page, stubs bool, err error) error {
+	stuck := []string{}
+	for i := len(moves) - 1; i >= 0; i-- {
+		if stubs {
+			removePage(moves[i].from)
+		}
+		if movePage(pages[i], moves[i].from) != nil {
+			stuck = append(stuck, moves[i].to)
+		}
+		removeEmptyDirs(strings.TrimSuffix(pageFile(moves[i].to), ".txt"))
+	}
+
+	if len(stuck) > 0 {
+		return fmt.Errorf("%s; %s could not be moved back", err, strings.Join(stuck, ", "))
+	}
+	return err
+}
+
+// movePage moves a page's file to a new title.  The file is renamed,
+// rather than copied, so that titles differing only in case work on
+// file systems that ignore case.
+func movePage(p *Page, newTitle string) error {
+	file := pageFile(newTitle)
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return err
+	}
+	if err := os.Rename(pageFile(p.Title), file); err != nil {
+		return err
+	}
+
+	unindexPage(p.Title)
+	p.Title = newTitle
+	indexPage(p)
+	return nil
+}
+
+// removeEmptyDirs removes a directory, and the directories under it,
+// if there are no pages left in them.
+func removeEmptyDirs(dir string) {
+	dirs := []string{}
+	filepath.Walk(dir, func(file string, info os.FileInfo, err error) error {
+		if err == nil && info.IsDir() {
+			dirs = append(dirs, file)
+		}
+		return nil
+	})
+
+	for i := len(dirs) - 1; i >= 0; i-- {
+		os.Remove(dirs[i])
+	}
+}
+
+// renameHandler shows a form for renaming a page, and renames it when
+// the form is posted.
+func renameHandler(w http.ResponseWriter, r *http.Request, title string) {
+	if r.Method != "POST" {
+		p, err := loadPage(title)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		renderTemplate(w, "rename", p)
+		return
+	}
+
+	newTitle := wikilang.CanonicalTitle(r.FormValue("title"))
+	if !titleValidator.MatchString(newTitle) {
+		http.Error(w, fmt.Sprintf("%s is not a valid title", newTitle), http.StatusBadRequest)
+		return
+	}
+	if newTitle == title {
+		http.Redirect(w, r, proxyRoot()+viewPath+title, http.StatusFound)
+		return
+	}
+
+	if _, err := renamePage(title, newTitle, r.FormValue("redirect") != ""); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	http.Redirect(w, r, proxyRoot()+viewPath+newTitle, http.StatusFound)
+}
@@ -0,0 +1,163 @@
+// Copyright (c) 2014, Daniel Gallagher
+// Use of this source code is covered by the MIT License, the full
+// text of which can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestRenamePage(t *testing.T) {
+	pages := map[string]string{
+		"RenameTestOld":       "Links to [RenameTestOld] itself.",
+		"RenameTestReferrer":  "See [RenameTestOld], not {[RenameTestOld]}.",
+		"RenameTestUnrelated": "See [RenameTestOldToo].",
+	}
+	for title, body := range pages {
+		(&Page{Title: title, Body: []byte(body)}).save()
+	}
+	defer func() {
+		for _, title := range []string{"RenameTestOld", "RenameTestNew", "RenameTestReferrer", "RenameTestUnrelated"} {
+			os.Remove(pageFile(title))
+		}
+	}()
+
+	if _, err := renamePage("RenameTestOld", "RenameTestReferrer", true); err == nil {
+		t.Errorf("Expected an error renaming to an existing page")
+	}
+
+	changed, err := renamePage("RenameTestOld", "RenameTestNew", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compare(t, strings.Join(changed, " "), "RenameTestNew RenameTestReferrer")
+
+	for title, expected := range map[string]string{
+		"RenameTestNew":       "Links to [RenameTestNew] itself.",
+		"RenameTestOld":       "#redirect [RenameTestNew]\n",
+		"RenameTestReferrer":  "See [RenameTestNew], not {[RenameTestOld]}.",
+		"RenameTestUnrelated": "See [RenameTestOldToo].",
+	} {
+		p, err := loadPage(title)
+		if err != nil {
+			t.Errorf("Could not load %s: %s", title, err)
+			continue
+		}
+		compare(t, string(p.Body), expected)
+	}
+
+	if _, err := renamePage("RenameTestNew", "RenameTestNewer", false); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(pageFile("RenameTestNewer"))
+	if _, err := loadPage("RenameTestNew"); err == nil {
+		t.Errorf("Expected RenameTestNew to be gone without a redirect")
+	}
+}
+
+func TestRenameSubpages(t *testing.T) {
+	pages := map[string]string{
+		"RenameTree":                  "See [./Child] and [RenameTree/Child/Grandchild].",
+		"RenameTree/Child":            "Back to [..], down to [./Grandchild].",
+		"RenameTree/Child/Grandchild": "Up to [RenameTree/Child].",
+		"RenameTreeReferrer":          "See [RenameTree/Child] and [RenameTreeToo].",
+	}
+	for title, body := range pages {
+		(&Page{Title: title, Body: []byte(body)}).save()
+	}
+	defer func() {
+		for _, dir := range []string{"RenameTree", "MovedTree"} {
+			os.RemoveAll(dataDir + dir)
+			removePage(dir)
+		}
+		removePage("RenameTreeReferrer")
+	}()
+
+	if _, err := renamePage("RenameTree", "RenameTree/Child/Deeper", false); err == nil {
+		t.Errorf("Expected an error moving a page under itself")
+	}
+
+	changed, err := renamePage("RenameTree", "MovedTree", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compare(t, strings.Join(changed, " "), "MovedTree MovedTree/Child/Grandchild RenameTreeReferrer")
+
+	for title, expected := range map[string]string{
+		"MovedTree":                  "See [./Child] and [MovedTree/Child/Grandchild].",
+		"MovedTree/Child":            "Back to [..], down to [./Grandchild].",
+		"MovedTree/Child/Grandchild": "Up to [MovedTree/Child].",
+		"RenameTreeReferrer":         "See [MovedTree/Child] and [RenameTreeToo].",
+	} {
+		p, err := loadPage(title)
+		if err != nil {
+			t.Errorf("Could not load %s: %s", title, err)
+			continue
+		}
+		compare(t, string(p.Body), expected)
+	}
+
+	if _, err := os.Stat(dataDir + "RenameTree"); err == nil {
+		t.Errorf("Expected the old subpage directory to be removed")
+	}
+}
+
+func TestRenameRollback(t *testing.T) {
+	pages := map[string]string{
+		"RollbackTree":            "See [RollbackTree/Deep/Child].",
+		"RollbackTree/Deep/Child": "Child",
+	}
+	for title, body := range pages {
+		(&Page{Title: title, Body: []byte(body)}).save()
+	}
+	defer func() {
+		for _, dir := range []string{"RollbackTree", "RolledTree"} {
+			os.RemoveAll(dataDir + dir)
+			removePage(dir)
+		}
+	}()
+
+	// A file where the child's new directory belongs stops the rename
+	// after the top page has moved.
+	os.MkdirAll(dataDir+"RolledTree", 0700)
+	ioutil.WriteFile(dataDir+"RolledTree/Deep", []byte("not a page"), 0600)
+
+	if _, err := renamePage("RollbackTree", "RolledTree", true); err == nil {
+		t.Fatalf("Expected an error moving a page into a file")
+	}
+
+	for title, expected := range pages {
+		p, err := loadPage(title)
+		if err != nil {
+			t.Errorf("Could not load %s: %s", title, err)
+			continue
+		}
+		compare(t, string(p.Body), expected)
+	}
+	if _, err := os.Stat(pageFile("RolledTree")); err == nil {
+		t.Errorf("Expected the moved page to be moved back")
+	}
+}
+
+func TestRenameCase(t *testing.T) {
+	(&Page{Title: "Renamecasetest", Body: []byte("Text")}).save()
+	defer removePage("RenameCaseTest")
+
+	if _, err := renamePage("Renamecasetest", "RenameCaseTest", true); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := loadPage("renamecasetest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	compare(t, p.Title, "RenameCaseTest")
+	compare(t, string(p.Body), "Text")
+	if _, err := os.Stat(pageFile("RenameCaseTest")); err != nil {
+		t.Errorf("Expected the page's file to be renamed: %s", err)
+	}
+}
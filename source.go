@@ -0,0 +1,47 @@
+// Copyright (c) 2014, Daniel Gallagher
+// Use of this source code is covered by the MIT License, the full
+// text of which can be found in the LICENSE file.
+
+package main
+
+import (
+	"github.com/danielgallagher0/docwiki/wikilang"
+	"net/http"
+	"os"
+	"strings"
+)
+
+const sourcePath = "/src/"
+
+// sourceHandler serves /src/<project>/<path> as plain text from the
+// project's source checkout.  Nothing outside of the checkout is
+// served.
+func sourceHandler(w http.ResponseWriter, r *http.Request) {
+	parts := strings.SplitN(r.URL.Path[len(sourcePath):], "/", 2)
+	if len(parts) != 2 {
+		http.NotFound(w, r)
+		return
+	}
+
+	file, err := wikilang.SourceFile(parts[0], parts[1])
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+}
@@ -0,0 +1,246 @@
+// Copyright (c) 2014, Daniel Gallagher
+// Use of this source code is covered by the MIT License, the full
+// text of which can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const deletePath = "/delete/"
+const trashPath = "/trash"
+const restorePath = "/trash/restore"
+
+const trashDir = "trash/"
+
+// defaultTrashRetention is how long deleted pages are kept if
+// docwiki.conf does not say.
+const defaultTrashRetention = 30 * 24 * time.Hour
+
+var trashRetention = defaultTrashRetention
+
+// SetTrashRetention sets how long deleted pages are kept before they
+// are purged from the trash.  Pages are kept forever if the retention
+// is negative.
+func SetTrashRetention(retention time.Duration) {
+	trashRetention = retention
+}
+
+// A trashEntry is a deleted page.  Each entry is stored as JSON in its
+// own file in the trash directory, named by its id.
+type trashEntry struct {
+	Id        string    `json:"-"`
+	Title     string    `json:"title"`
+	DeletedBy string    `json:"deletedBy"`
+	DeletedAt time.Time `json:"deletedAt"`
+	Body      string    `json:"body"`
+}
+
+func trashFile(id string) string {
+	return trashDir + id + ".json"
+}
+
+// validTrashId reports whether id could name an entry in the trash,
+// so that ids from requests cannot name other files.
+func validTrashId(id string) bool {
+	return id != "" && id == filepath.Base(id) && !strings.HasPrefix(id, ".")
+}
+
+// requester names who made a request: the user, if the wiki is behind
+// a proxy that does authentication, or else the client's address.
+func requester(r *http.Request) string {
+	if user, _, ok := r.BasicAuth(); ok {
+		return user
+	}
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		return strings.TrimSpace(strings.Split(forwarded, ",")[0])
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// deletePage moves a page to the trash.
+func deletePage(title, who string, when time.Time) error {
+	p, err := loadPage(title)
+	if err != nil {
+		return fmt.Errorf("%s does not exist", title)
+	}
+	title = p.Title
+
+	entry := trashEntry{Title: title, DeletedBy: who, DeletedAt: when, Body: string(p.Body)}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(trashDir, 0700); err != nil {
+		return err
+	}
+	id := fmt.Sprintf("%d-%s", when.UnixNano(), url.QueryEscape(title))
+	if err := ioutil.WriteFile(trashFile(id), data, 0600); err != nil {
+		return err
+	}
+
+	return removePage(title)
+}
+
+func readTrashEntry(id string) (trashEntry, error) {
+	var entry trashEntry
+
+	data, err := ioutil.ReadFile(trashFile(id))
+	if err != nil {
+		return entry, err
+	}
+	if err = json.Unmarshal(data, &entry); err != nil {
+		return entry, err
+	}
+
+	entry.Id = id
+	return entry, nil
+}
+
+// trashEntries returns everything in the trash, most recently deleted
+// first.
+func trashEntries() []trashEntry {
+	entries := []trashEntry{}
+
+	files, err := ioutil.ReadDir(trashDir)
+	if err != nil {
+		return entries
+	}
+
+	for _, info := range files {
+		name := info.Name()
+		if info.IsDir() || filepath.Ext(name) != ".json" {
+			continue
+		}
+
+		if entry, err := readTrashEntry(name[:len(name)-5]); err == nil {
+			entries = append(entries, entry)
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].DeletedAt.After(entries[j].DeletedAt)
+	})
+	return entries
+}
+
+// restorePage moves a page out of the trash.  A page cannot be
+// restored over a page that has since been created with the same
+// title, in any case.
+func restorePage(id string) (string, error) {
+	if !validTrashId(id) {
+		return "", fmt.Errorf("%s is not in the trash", id)
+	}
+
+	entry, err := readTrashEntry(id)
+	if err != nil {
+		return "", fmt.Errorf("%s is not in the trash", id)
+	}
+	if _, err := os.Stat(pageFile(entry.Title)); err == nil {
+		return "", fmt.Errorf("%s already exists", entry.Title)
+	}
+	if existing, ok := indexedPage(entry.Title); ok {
+		return "", fmt.Errorf("%s already exists", existing.title)
+	}
+
+	p := &Page{Title: entry.Title, Body: []byte(entry.Body)}
+	if err := p.save(); err != nil {
+		return "", err
+	}
+
+	return entry.Title, os.Remove(trashFile(id))
+}
+
+// purgeTrash permanently removes pages that have been in the trash
+// longer than the retention period.  It returns the number of pages
+// removed.
+func purgeTrash(now time.Time) int {
+	if trashRetention < 0 {
+		return 0
+	}
+
+	purged := 0
+	for _, entry := range trashEntries() {
+		if now.Sub(entry.DeletedAt) > trashRetention {
+			if os.Remove(trashFile(entry.Id)) == nil {
+				purged++
+			}
+		}
+	}
+	return purged
+}
+
+// writeTrash writes wiki text listing the pages in the trash, each
+// with a button to restore it.
+func writeTrash(w io.Writer, entries []trashEntry) {
+	if len(entries) == 0 {
+		fmt.Fprintf(w, "The trash is empty.\n")
+		return
+	}
+
+	if trashRetention >= 0 {
+		fmt.Fprintf(w, "Deleted pages are kept for %d days.\n\n", int(trashRetention.Hours()/24))
+	}
+
+	for _, entry := range entries {
+		fmt.Fprintf(w, "    - {%s} deleted by %s on %s <form action=\"%s%s\" method=\"POST\">"+
+			"<input type=\"hidden\" name=\"id\" value=\"%s\" /><input type=\"submit\" value=\"Restore\" /></form>\n",
+			entry.Title, template.HTMLEscapeString(entry.DeletedBy), entry.DeletedAt.Format("2006-01-02 15:04"),
+			proxyRoot(), restorePath, template.HTMLEscapeString(entry.Id))
+	}
+}
+
+// deleteHandler moves a page to the trash.  Pages are only deleted by
+// POST requests, so that following a link cannot delete a page.
+func deleteHandler(w http.ResponseWriter, r *http.Request, title string) {
+	if r.Method != "POST" {
+		http.Error(w, "delete requires POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := deletePage(title, requester(r), time.Now()); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	http.Redirect(w, r, proxyRoot()+trashPath, http.StatusFound)
+}
+
+func trashHandler(w http.ResponseWriter, r *http.Request) {
+	purgeTrash(time.Now())
+
+	var body bytes.Buffer
+	writeTrash(&body, trashEntries())
+	renderTemplate(w, "report", &Page{Title: "Trash", Body: body.Bytes()})
+}
+
+func restoreHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "restore requires POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	title, err := restorePage(r.FormValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	http.Redirect(w, r, proxyRoot()+viewPath+title, http.StatusFound)
+}
@@ -0,0 +1,116 @@
+// Copyright (c) 2014, Daniel Gallagher
+// Use of this source code is covered by the MIT License, the full
+// text of which can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTrash(t *testing.T) {
+	if _, err := os.Stat(trashDir); err == nil {
+		t.Skip("trash/ already exists")
+	}
+	defer os.RemoveAll(trashDir)
+	defer os.Remove(pageFile("TrashTestPage"))
+
+	(&Page{Title: "TrashTestPage", Body: []byte("Old text")}).save()
+	deleted := time.Date(2014, 5, 1, 12, 30, 0, 0, time.UTC)
+	if err := deletePage("TrashTestPage", "alice", deleted); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadPage("TrashTestPage"); err == nil {
+		t.Errorf("Expected the page to be deleted")
+	}
+	if err := deletePage("TrashTestPage", "alice", deleted); err == nil {
+		t.Errorf("Expected an error deleting a missing page")
+	}
+
+	entries := trashEntries()
+	if len(entries) != 1 || entries[0].Title != "TrashTestPage" || entries[0].DeletedBy != "alice" ||
+		!entries[0].DeletedAt.Equal(deleted) || entries[0].Body != "Old text" {
+		t.Fatalf("Unexpected trash %v", entries)
+	}
+
+	var out bytes.Buffer
+	writeTrash(&out, entries)
+	compare(t, out.String(), "Deleted pages are kept for 30 days.\n\n"+
+		"    - {TrashTestPage} deleted by alice on 2014-05-01 12:30 <form action=\"/trash/restore\" method=\"POST\">"+
+		"<input type=\"hidden\" name=\"id\" value=\""+entries[0].Id+"\" /><input type=\"submit\" value=\"Restore\" /></form>\n")
+
+	if _, err := restorePage("../data/FrontPage"); err == nil {
+		t.Errorf("Expected an error restoring a file outside of the trash")
+	}
+
+	(&Page{Title: "TrashTestPage", Body: []byte("New text")}).save()
+	if _, err := restorePage(entries[0].Id); err == nil {
+		t.Errorf("Expected an error restoring over an existing page")
+	}
+	os.Remove(pageFile("TrashTestPage"))
+
+	(&Page{Title: "Trashtestpage", Body: []byte("New text")}).save()
+	if _, err := restorePage(entries[0].Id); err == nil {
+		t.Errorf("Expected an error restoring over a page that differs only in case")
+	}
+	os.Remove(pageFile("Trashtestpage"))
+
+	title, err := restorePage(entries[0].Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compare(t, title, "TrashTestPage")
+	if p, err := loadPage("TrashTestPage"); err != nil || string(p.Body) != "Old text" {
+		t.Errorf("Expected the page to be restored")
+	}
+	if len(trashEntries()) != 0 {
+		t.Errorf("Expected the trash to be empty")
+	}
+
+	if err := deletePage("trashtestpage", "bob", deleted); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(pageFile("TrashTestPage")); err == nil {
+		t.Errorf("Expected the page to be deleted when spelled in another case")
+	}
+	if entries := trashEntries(); len(entries) != 1 || entries[0].Title != "TrashTestPage" {
+		t.Errorf("Unexpected trash %v", entries)
+	}
+	if purged := purgeTrash(deleted.Add(29 * 24 * time.Hour)); purged != 0 {
+		t.Errorf("Expected nothing to be purged before 30 days, got %d", purged)
+	}
+	if purged := purgeTrash(deleted.Add(31 * 24 * time.Hour)); purged != 1 {
+		t.Errorf("Expected 1 page to be purged after 30 days, got %d", purged)
+	}
+}
+
+func TestEmptySaveDeletes(t *testing.T) {
+	if _, err := os.Stat(trashDir); err == nil {
+		t.Skip("trash/ already exists")
+	}
+	defer os.RemoveAll(trashDir)
+	defer os.Remove(pageFile("TrashTestEmpty"))
+
+	(&Page{Title: "TrashTestEmpty", Body: []byte("Text")}).save()
+
+	form := url.Values{"body": {"  \r\n"}}
+	request := httptest.NewRequest("POST", "/save/TrashTestEmpty", strings.NewReader(form.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.RemoteAddr = "192.0.2.1:1234"
+	w := httptest.NewRecorder()
+	saveHandler(w, request, "TrashTestEmpty")
+
+	compare(t, w.Header().Get("Location"), "/trash")
+	if _, err := loadPage("TrashTestEmpty"); err == nil {
+		t.Errorf("Expected the page to be deleted")
+	}
+	if entries := trashEntries(); len(entries) != 1 || entries[0].DeletedBy != "192.0.2.1" {
+		t.Errorf("Unexpected trash %v", entries)
+	}
+}
@@ -10,7 +10,6 @@
 package main
 
 import (
-	"bufio"
 	"bytes"
 	"fmt"
 	"github.com/danielgallagher0/docwiki/wikilang"
@@ -21,6 +20,9 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
+	"strings"
+	"time"
 )
 
 // Page is a container for wiki pages.  The fields are exported so
@@ -29,6 +31,10 @@ import (
 type Page struct {
 	Title string
 	Body  []byte
+	Meta  map[string]string
+
+	RedirectedFrom *Breadcrumb // Page that redirected to this one
+	RedirectLoop   bool        // Whether the page's redirect was not followed
 }
 
 const viewPath = "/view/"
@@ -40,68 +46,215 @@ const docPath = "/doc/"
 const dataDir = "data/"
 const tmplDir = "tmpl/"
 
-const titleRegexp = "[A-Za-z0-9]+"
+// Page titles are slash-separated, like ProjA/Design/Threading, and
+// each part is stored as a directory under dataDir.  Titles may use
+// letters and digits from any script.
+const titleRegexp = `[\pL\pM\pN]+(/[\pL\pM\pN]+)*`
 
 var templates = template.Must(template.ParseFiles(tmplDir+"edit.html",
 	tmplDir+"view.html",
-	tmplDir+"search.html"))
+	tmplDir+"search.html",
+	tmplDir+"doclinks.html",
+	tmplDir+"report.html",
+	tmplDir+"rename.html"))
 var titleValidator = regexp.MustCompile("^" + titleRegexp + "$")
 
 var proxyRootPath string
+var projectPrefixes map[string]string
+var autoLink bool
 
 func SetProxyRoot(p string) {
 	proxyRootPath = p
 }
 
+// SetProjectPrefixes sets the default doclink project for pages whose
+// titles start with a given prefix.  For example, mapping "Big" to
+// "bigprojectname" makes [doc::Entity] on the page BigThreading link
+// to bigprojectname's Doxygen.
+func SetProjectPrefixes(prefixes map[string]string) {
+	projectPrefixes = prefixes
+}
+
+// SetAutoLink sets whether literal text that names a Doxygen entity
+// is linked to the entity's documentation on pages that do not say
+// otherwise with an #autolink line.
+func SetAutoLink(enabled bool) {
+	autoLink = enabled
+}
+
 func proxyRoot() string {
 	return proxyRootPath
 }
 
+// PrettyTitle returns the page's display title, if it has a "#title"
+// line at the top, or else the last part of the page's title, with
+// spaces between the words.  The rest of the title is in the
+// breadcrumbs.
 func (p *Page) PrettyTitle() string {
-	return wikilang.WikiCase(p.Title)
+	meta := p.Meta
+	if meta == nil {
+		meta, _ = wikilang.ParseMetadata(string(p.Body))
+	}
+	if title := meta["title"]; title != "" {
+		return title
+	}
+
+	return wikilang.WikiCase(p.Title[strings.LastIndex(p.Title, "/")+1:])
+}
+
+// displayTitle returns the text used for links to a page: its
+// PrettyTitle if it exists, or else the last part of the title, with
+// spaces between the words.
+func displayTitle(title string) string {
+	if entry, ok := indexedPage(title); ok && entry.display != "" {
+		return entry.display
+	}
+	return (&Page{Title: title}).PrettyTitle()
+}
+
+// A Breadcrumb links to one of the pages above a page.
+type Breadcrumb struct {
+	Title string
+	Text  string
+}
+
+// Breadcrumbs returns the pages above a page, from the top level
+// down.  For example, ProjA/Design/Threading has the breadcrumbs ProjA
+// and ProjA/Design.
+func (p *Page) Breadcrumbs() []Breadcrumb {
+	crumbs := []Breadcrumb{}
+	parts := strings.Split(p.Title, "/")
+	for i := 1; i < len(parts); i++ {
+		title := strings.Join(parts[:i], "/")
+		crumbs = append(crumbs, Breadcrumb{title, displayTitle(title)})
+	}
+	return crumbs
 }
 
 func (p *Page) ProxyRoot() string {
 	return proxyRoot()
 }
 
+// view returns a copy of the page that is ready to be viewed.  The
+// metadata lines are removed from the body and stored in Meta.
+func (p *Page) view() *Page {
+	meta, body := wikilang.ParseMetadata(string(p.Body))
+	return &Page{Title: p.Title, Body: []byte(body), Meta: meta}
+}
+
+// docProject returns the default project for doclinks on the page.
+// A #project metadata line takes precedence over the longest matching
+// title prefix.
+func (p *Page) docProject() string {
+	if project, ok := p.Meta["project"]; ok {
+		return project
+	}
+
+	project := ""
+	longest := -1
+	for prefix, name := range projectPrefixes {
+		if strings.HasPrefix(p.Title, prefix) && len(prefix) > longest {
+			project = name
+			longest = len(prefix)
+		}
+	}
+
+	return project
+}
+
+// autoLink returns whether literal text on the page is automatically
+// linked to Doxygen.  An "#autolink" or "#autolink on" line at the top
+// of the page turns it on, and "#autolink off" turns it off.
+func (p *Page) autoLink() bool {
+	if value, ok := p.Meta["autolink"]; ok {
+		return value != "off"
+	}
+	return autoLink
+}
+
+func (p *Page) renderOptions() wikilang.Options {
+	return wikilang.Options{Project: p.docProject(), AutoLink: p.autoLink(), Root: proxyRoot() + "/", Page: p.Title}
+}
+
 func renderTemplate(w http.ResponseWriter, file string, p *Page) {
+	body, err := executeTemplate(file, p)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Write(body)
+}
+
+// executeTemplate fills in a template for a page, and renders the
+// result as wiki text for the templates that hold wiki text.
+func executeTemplate(file string, p *Page) ([]byte, error) {
 	var buf bytes.Buffer
 
 	err := templates.ExecuteTemplate(&buf, file+".html", p)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		return nil, err
 	}
 
 	body := buf.Bytes()
 
-	if file == "view" || file == "search" {
-		body = []byte(wikilang.WikiToHtml(string(body)))
+	if file == "view" || file == "search" || file == "doclinks" || file == "report" {
+		body = []byte(wikilang.PageToHtml(string(body), p.renderOptions()))
 	}
 
-	w.Write(body)
+	return body, nil
 }
 
+// viewHandler shows a page.  Pages that redirect to another page, and
+// aliases of other pages, are sent on to the page they name, unless
+// the request has "redirect=no" so that the redirect can be edited.
 func viewHandler(w http.ResponseWriter, r *http.Request, title string) {
+	loop := false
+	if r.FormValue("redirect") != "no" {
+		target, err := followRedirects(title)
+		if err == nil && target != title {
+			u := url.URL{Path: proxyRoot() + viewPath + target, RawQuery: url.Values{"from": {title}}.Encode()}
+			http.Redirect(w, r, u.String(), http.StatusFound)
+			return
+		}
+		loop = err != nil
+	}
+
 	p, err := loadPage(title)
 	if err != nil {
 		http.Redirect(w, r, proxyRoot()+editPath+title, http.StatusFound)
 		return
 	}
-	renderTemplate(w, "view", p)
+
+	view := p.view()
+	view.RedirectLoop = loop
+	if from := r.FormValue("from"); titleValidator.MatchString(from) {
+		view.RedirectedFrom = &Breadcrumb{from, displayTitle(from)}
+	}
+	renderTemplate(w, "view", view)
 }
 
 func editHandler(w http.ResponseWriter, r *http.Request, title string) {
 	p, err := loadPage(title)
 	if err != nil {
-		p = &Page{Title: title}
+		p = &Page{Title: title, Body: entitySkeleton(r.FormValue(entityParam))}
 	}
 	renderTemplate(w, "edit", p)
 }
 
 func saveHandler(w http.ResponseWriter, r *http.Request, title string) {
 	body := r.FormValue("body")
+	if strings.TrimSpace(body) == "" {
+		// Saving an empty page deletes it.  There is nothing to delete
+		// if the page was never saved.
+		if _, err := loadPage(title); err == nil {
+			deleteHandler(w, r, title)
+			return
+		}
+		http.Redirect(w, r, proxyRoot()+viewPath+title, http.StatusFound)
+		return
+	}
+
 	p := &Page{Title: title, Body: []byte(body)}
 	err := p.save()
 	if err != nil {
@@ -111,71 +264,193 @@ func saveHandler(w http.ResponseWriter, r *http.Request, title string) {
 	http.Redirect(w, r, proxyRoot()+viewPath+title, http.StatusFound)
 }
 
+// searchHandler lists the pages that link to a page, including the
+// pages that link to it through a redirect or an alias.
 func searchHandler(w http.ResponseWriter, r *http.Request, title string) {
-	matches := make(chan string)
-	count := 0
+	names := redirectsTo(title)
 
-	filepath.Walk(dataDir, func(path string, info os.FileInfo, err error) error {
-		count++
-		go func(file, target string) {
-			match := ""
+	var body bytes.Buffer
+	for _, page := range listPages() {
+		p, err := loadPage(page)
+		if err != nil {
+			continue
+		}
 
-			f, err := os.Open(file)
-			if err != nil {
-				matches <- ""
-			}
+		view := p.view()
+		if linksToAny(wikilang.Links(string(view.Body), view.renderOptions()), names) {
+			fmt.Fprintf(&body, "- [%s]\n", page)
+		}
+	}
+	p := &Page{Title: title, Body: body.Bytes()}
 
-			re := regexp.MustCompile(fmt.Sprintf("\\[%s\\]", target))
-			s := bufio.NewScanner(f)
-			for s.Scan() {
-				str := s.Text()
+	renderTemplate(w, "search", p)
+}
 
-				if len(re.FindString(str)) > 0 {
-					match = file[len(dataDir):]
-					match = match[0 : len(match)-4]
-					break
-				}
+// linksToAny returns whether any of a page's links go to one of the
+// titles.
+func linksToAny(links, titles []string) bool {
+	for _, link := range links {
+		link, _ = wikilang.SplitFragment(link)
+		for _, title := range titles {
+			if strings.EqualFold(wikilang.CanonicalTitle(link), title) {
+				return true
 			}
+		}
+	}
+	return false
+}
 
-			matches <- match
-		}(path, title)
-
-		return nil
-	})
+// pageFile returns the name of the file that a page is stored in.
+// Pages with slash-separated titles are stored in subdirectories.
+// Anything other than ASCII letters and digits is escaped, so titles
+// in any script are safe to use as file names on any file system.
+func pageFile(title string) string {
+	parts := strings.Split(wikilang.NormalizeTitle(title), "/")
+	for i, part := range parts {
+		parts[i] = url.QueryEscape(part)
+	}
+	return dataDir + strings.Join(parts, "/") + ".txt"
+}
 
-	var body []byte
-	for i := 0; i < count; i++ {
-		match := <-matches
-		if len(match) > 0 {
-			body = append(body, []byte(fmt.Sprintf("- [%s]\n", match))...)
+// fileTitle returns the title of the page stored in a file under
+// dataDir, given the file's path relative to dataDir.
+func fileTitle(name string) (string, error) {
+	parts := strings.Split(filepath.ToSlash(strings.TrimSuffix(name, ".txt")), "/")
+	for i, part := range parts {
+		title, err := url.QueryUnescape(part)
+		if err != nil {
+			return "", err
 		}
+		parts[i] = title
 	}
-	p := &Page{Title: title, Body: body}
+	return wikilang.NormalizeTitle(strings.Join(parts, "/")), nil
+}
 
-	renderTemplate(w, "search", p)
+// canonicalTitle returns the title of the page that a title names.
+// The title is put in canonical form, then matched to an existing
+// page without regard to case, so [front page], [Front Page] and
+// [FrontPage] are all the page FrontPage.  Titles that do not match a
+// page are returned in canonical form.
+func canonicalTitle(title string) string {
+	title = wikilang.CanonicalTitle(title)
+	if _, err := os.Stat(pageFile(title)); err == nil {
+		return title
+	}
+
+	if entry, ok := indexedPage(title); ok {
+		return entry.title
+	}
+	return title
 }
 
 func loadPage(title string) (*Page, error) {
-	filename := dataDir + url.QueryEscape(title) + ".txt"
-	body, err := ioutil.ReadFile(filename)
+	title = canonicalTitle(title)
+	body, err := ioutil.ReadFile(pageFile(title))
 	if err != nil {
 		return nil, err
 	}
 	return &Page{Title: title, Body: body}, nil
 }
 
+// listPages returns the titles of all of the pages in the wiki,
+// including pages in subdirectories.
+func listPages() []string {
+	titles := []string{}
+
+	filepath.Walk(dataDir, func(file string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || filepath.Ext(file) != ".txt" {
+			return nil
+		}
+
+		name, err := filepath.Rel(dataDir, file)
+		if err != nil {
+			return nil
+		}
+		if title, err := fileTitle(name); err == nil {
+			titles = append(titles, title)
+		}
+		return nil
+	})
+
+	return titles
+}
+
+// subpages returns the titles of the pages directly under a page,
+// sorted.  For example, ProjA/Design is a subpage of ProjA, but
+// ProjA/Design/Threading is not.
+func subpages(title string) []string {
+	children := []string{}
+	for _, page := range listPages() {
+		if strings.HasPrefix(page, title+"/") && !strings.Contains(page[len(title)+1:], "/") {
+			children = append(children, page)
+		}
+	}
+	sort.Strings(children)
+	return children
+}
+
+func init() {
+	wikilang.RegisterBlockDirective("subpages", subpagesDirective)
+	wikilang.SetIndexChanged(checkReindexedProject)
+	wikilang.SetDisplayTitles(func(title string) string {
+		entry, _ := indexedPage(title)
+		return entry.display
+	})
+}
+
+// subpagesDirective renders [subpages] as a list of links to the
+// pages directly under the page it is on, or [subpages:Title] as the
+// pages under another page.  The title may be relative.
+func subpagesDirective(args string, options wikilang.Options) wikilang.ParseNode {
+	title := options.Page
+	if args != "" {
+		title = wikilang.ResolveTitle(options.Page, args)
+	}
+
+	children := subpages(title)
+	if len(children) == 0 {
+		return wikilang.TextNode{Text: "(no subpages)"}
+	}
+
+	items := []wikilang.ParseNode{}
+	for _, child := range children {
+		link := wikilang.Token{Type: wikilang.WikiLink, TextValue: child}.ToNode()
+		items = append(items, wikilang.TagNode{Tag: wikilang.ListItem, Attributes: map[string]string{},
+			Tree: wikilang.ParseTree{Nodes: []wikilang.ParseNode{link}}})
+	}
+	return wikilang.TagNode{Tag: wikilang.UnorderedList, Attributes: map[string]string{},
+		Tree: wikilang.ParseTree{Nodes: items}}
+}
+
 func (p *Page) save() error {
-	filename := dataDir + url.QueryEscape(p.Title) + ".txt"
-	return ioutil.WriteFile(filename, p.Body, 0600)
+	p.Title = canonicalTitle(p.Title)
+	file := pageFile(p.Title)
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(file, p.Body, 0600); err != nil {
+		return err
+	}
+	indexPage(p)
+	return nil
 }
 
 func makeHandler(handler func(http.ResponseWriter, *http.Request, string), path string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		title := r.URL.Path[len(path):]
+		requested := r.URL.Path[len(path):]
+		title := canonicalTitle(requested)
 		if !titleValidator.MatchString(title) {
 			http.NotFound(w, r)
 			return
 		}
+
+		// Other spellings of a title are sent to the canonical URL, so
+		// that each page has only one.
+		if title != requested && r.Method == "GET" {
+			u := url.URL{Path: proxyRoot() + path + title, RawQuery: r.URL.RawQuery}
+			http.Redirect(w, r, u.String(), http.StatusMovedPermanently)
+			return
+		}
 		handler(w, r, title)
 	}
 }
@@ -184,10 +459,6 @@ func redirectToFrontPage(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, proxyRoot()+viewPath+"FrontPage", http.StatusFound)
 }
 
-func fileHandler(w http.ResponseWriter, r *http.Request) {
-	http.ServeFile(w, r, r.URL.Path[1:])
-}
-
 func ListenAndServe(port int) {
 	http.HandleFunc("/", redirectToFrontPage)
 	http.HandleFunc(viewPath, makeHandler(viewHandler, viewPath))
@@ -195,6 +466,22 @@ func ListenAndServe(port int) {
 	http.HandleFunc(savePath, makeHandler(saveHandler, savePath))
 	http.HandleFunc(searchPath, makeHandler(searchHandler, searchPath))
 	http.HandleFunc(docPath, fileHandler)
+	http.HandleFunc(completeDocPath, completeDocHandler)
+	http.HandleFunc(completePagePath, completePageHandler)
+	http.HandleFunc(doclinksPath, doclinksHandler)
+	http.HandleFunc(reindexPath, reindexHandler)
+	http.HandleFunc(coveragePath, coverageHandler)
+	http.HandleFunc(entityPagePath, entityPageHandler)
+	http.HandleFunc(docChangesPath, docChangesHandler)
+	http.HandleFunc(projectsPath, projectsHandler)
+	http.HandleFunc(projectPath, projectHandler)
+	http.HandleFunc(sourcePath, sourceHandler)
+	http.HandleFunc(renamePath, makeHandler(renameHandler, renamePath))
+	http.HandleFunc(deletePath, makeHandler(deleteHandler, deletePath))
+	http.HandleFunc(trashPath, trashHandler)
+	http.HandleFunc(restorePath, restoreHandler)
+
+	purgeTrash(time.Now())
 
 	err := http.ListenAndServe(fmt.Sprintf(":%d", port), nil)
 	if err != nil {
@@ -6,7 +6,13 @@ package main
 
 import (
 	"github.com/danielgallagher0/docwiki/wikilang"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
 	"testing"
+	"unicode"
 )
 
 func compare(t *testing.T, actual, expected string) {
@@ -31,10 +37,10 @@ func TestWikiLinks(t *testing.T) {
 		{data: "[LinkOnly]", expected: "<p>\n  <a href=\"/view/LinkOnly\">Link Only</a>\n</p>\n"},
 		{data: "An embedded [Link] in some text", expected: "<p>\n  An embedded <a href=\"/view/Link\">Link</a> in some text\n</p>\n"},
 		{data: "Multiple [WikiLinks] embedded in [LotsOfText].", expected: "<p>\n  Multiple <a href=\"/view/WikiLinks\">Wiki Links</a> embedded in <a href=\"/view/LotsOfText\"\n  >Lots Of Text</a>.\n</p>\n"},
-		{data: "Spread over [Multiple\nLines]", expected: "<p>\n  Spread over <a href=\"/view/Multiple%0ALines\">Multiple Lines</a>\n</p>\n"},
+		{data: "Spread over [Multiple\nLines]", expected: "<p>\n  Spread over <a href=\"/view/MultipleLines\">Multiple Lines</a>\n</p>\n"},
 		{data: "Not a[SeparateWord]", expected: "<p>\n  Not a <a href=\"/view/SeparateWord\">Separate Word</a>\n</p>\n"},
 		{data: "Not [SeparateWord]s", expected: "<p>\n  Not <a href=\"/view/SeparateWord\">Separate Word</a> s\n</p>\n"},
-		{data: "[Multiple Words]", expected: "<p>\n  <a href=\"/view/Multiple+Words\">Multiple Words</a>\n</p>\n"}})
+		{data: "[Multiple Words]", expected: "<p>\n  <a href=\"/view/MultipleWords\">Multiple Words</a>\n</p>\n"}})
 }
 
 func TestExternalLinks(t *testing.T) {
@@ -233,19 +239,255 @@ func TestOrderedList(t *testing.T) {
 
 func TestEmphasis(t *testing.T) {
 	compareViewText(t, []ViewTextTestData{
-		{data: "*Bold Only*", expected: "<p>\n  <b>Bold Only</b>\n</p>\n"},
+		{data: "*Bold Only*", expected: "<p id=\"bold-only\">\n  <b>Bold Only</b>\n</p>\n"},
 		{data: "An embedded *Bold* in some text", expected: "<p>\n  An embedded <b>Bold</b> in some text\n</p>\n"},
 		{data: "Multiple *Bold elements* embedded in *Lots Of Text*.", expected: "<p>\n  Multiple <b>Bold elements</b> embedded in <b>Lots Of Text</b>.\n</p>\n"},
 		{data: "Spread over *Multiple\nLines*", expected: "<p>\n  Spread over <b>Multiple Lines</b>\n</p>\n"},
-		{data: "Still a*Separate Word*", expected: "<p>\n  Still a <b>Separate Word</b>\n</p>\n"},
-		{data: "Still *Separate Word*s", expected: "<p>\n  Still <b>Separate Word</b> s\n</p>\n"},
-		{data: "/Bold Only/", expected: "<p>\n  <em>Bold Only</em>\n</p>\n"},
+		{data: "Still a*Separate Word*", expected: "<p>\n  Still a*Separate Word*\n</p>\n"},
+		{data: "Still *Separate Word*s", expected: "<p>\n  Still *Separate Word*s\n</p>\n"},
+		{data: "/Bold Only/", expected: "<p id=\"bold-only\">\n  <em>Bold Only</em>\n</p>\n"},
 		{data: "An embedded /Bold/ in some text", expected: "<p>\n  An embedded <em>Bold</em> in some text\n</p>\n"},
 		{data: "Multiple /Bold elements/ embedded in /Lots Of Text/.", expected: "<p>\n  Multiple <em>Bold elements</em> embedded in <em>Lots Of Text</em>.\n</p>\n"},
 		{data: "Spread over /Multiple\nLines/", expected: "<p>\n  Spread over <em>Multiple Lines</em>\n</p>\n"},
-		{data: "Still a/Separate Word/", expected: "<p>\n  Still a <em>Separate Word</em>\n</p>\n"},
-		{data: "Still /Separate Word/s", expected: "<p>\n  Still <em>Separate Word</em> s\n</p>\n"},
+		{data: "Still a/Separate Word/", expected: "<p>\n  Still a/Separate Word/\n</p>\n"},
+		{data: "Still /Separate Word/s", expected: "<p>\n  Still /Separate Word/s\n</p>\n"},
 		{data: "Mixed *bold and /emphasis/* or /emphasis and *bold*/", expected: "<p>\n  Mixed <b>bold and <em>emphasis</em></b> or <em>emphasis and <b>bold</b></em>\n</p>\n"},
-		{data: "Improper *mixing /of emphasis* and bold/", expected: "<p>\n  Improper <b>mixing <em>of emphasis</em> and bold</b>\n</p>\n"},
+		{data: "Improper *mixing /of emphasis* and bold/", expected: "<p>\n  Improper <b>mixing /of emphasis</b> and bold/\n</p>\n"},
 	})
 }
+
+func TestDocProject(t *testing.T) {
+	SetProjectPrefixes(map[string]string{"Big": "big", "BigGui": "gui"})
+	defer SetProjectPrefixes(nil)
+
+	for _, data := range [...]struct {
+		page     *Page
+		expected string
+	}{
+		{&Page{Title: "BigThreading"}, "big"},
+		{&Page{Title: "BigGuiWidgets"}, "gui"},
+		{&Page{Title: "SmallThreading"}, ""},
+		{(&Page{Title: "BigThreading", Body: []byte("#project other\nBody")}).view(), "other"},
+	} {
+		compare(t, data.page.docProject(), data.expected)
+	}
+}
+
+func TestAutoLinkOption(t *testing.T) {
+	for _, data := range [...]struct {
+		site     bool
+		body     string
+		expected bool
+	}{
+		{false, "Body", false},
+		{true, "Body", true},
+		{false, "#autolink\nBody", true},
+		{false, "#autolink on\nBody", true},
+		{true, "#autolink off\nBody", false},
+	} {
+		SetAutoLink(data.site)
+		p := (&Page{Title: "Test", Body: []byte(data.body)}).view()
+		if actual := p.renderOptions().AutoLink; actual != data.expected {
+			t.Errorf("Expected autolink %v for %q, got %v", data.expected, data.body, actual)
+		}
+	}
+	SetAutoLink(false)
+}
+
+func TestHierarchicalTitles(t *testing.T) {
+	compare(t, pageFile("ProjA/Design/Threading"), "data/ProjA/Design/Threading.txt")
+	title, err := fileTitle("ProjA/Design/Threading.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	compare(t, title, "ProjA/Design/Threading")
+
+	for _, title := range []string{"FrontPage", "ProjA/Design/Threading"} {
+		if !titleValidator.MatchString(title) {
+			t.Errorf("Expected %s to be a valid title", title)
+		}
+	}
+	for _, title := range []string{"ProjA/", "/ProjA", "ProjA//Design", "ProjA/../Design"} {
+		if titleValidator.MatchString(title) {
+			t.Errorf("Expected %s to be an invalid title", title)
+		}
+	}
+
+	p := &Page{Title: "ProjA/Design/ThreadModel"}
+	compare(t, p.PrettyTitle(), "Thread Model")
+	crumbs := p.Breadcrumbs()
+	if len(crumbs) != 2 || crumbs[0] != (Breadcrumb{"ProjA", "Proj A"}) || crumbs[1] != (Breadcrumb{"ProjA/Design", "Design"}) {
+		t.Errorf("Unexpected breadcrumbs %v", crumbs)
+	}
+}
+
+func TestSubpages(t *testing.T) {
+	defer os.RemoveAll(dataDir + "SubpageTest")
+	for _, title := range []string{"SubpageTest/Design", "SubpageTest/Design/Threading", "SubpageTest/Overview"} {
+		if err := (&Page{Title: title, Body: []byte("Text")}).save(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	compare(t, strings.Join(subpages("SubpageTest"), " "), "SubpageTest/Design SubpageTest/Overview")
+
+	p := &Page{Title: "SubpageTest/Design"}
+	compare(t, wikilang.PageToHtml("[subpages]\n\n[subpages:..]", p.renderOptions()),
+		"<ul>\n"+
+			"  \n"+
+			"  <li>\n"+
+			"    <a href=\"/view/SubpageTest/Design/Threading\">Threading</a>\n"+
+			"  </li>\n"+
+			"  \n"+
+			"</ul>\n"+
+			"\n\n"+
+			"<ul>\n"+
+			"  \n"+
+			"  <li>\n"+
+			"    <a href=\"/view/SubpageTest/Design\">Design</a>\n"+
+			"  </li>\n"+
+			"  \n"+
+			"  <li>\n"+
+			"    <a href=\"/view/SubpageTest/Overview\">Overview</a>\n"+
+			"  </li>\n"+
+			"  \n"+
+			"</ul>\n")
+}
+
+func TestUnicodeTitles(t *testing.T) {
+	for _, title := range []string{"Café", "日本語/設計", "Привет", "हिन्दी"} {
+		if !titleValidator.MatchString(title) {
+			t.Errorf("Expected %s to be a valid title", title)
+		}
+
+		file := pageFile(title)
+		for _, c := range file {
+			if c > unicode.MaxASCII {
+				t.Errorf("Expected %s to be stored in an ASCII file name, not %s", title, file)
+				break
+			}
+		}
+
+		actual, err := fileTitle(file[len(dataDir):])
+		if err != nil {
+			t.Fatal(err)
+		}
+		compare(t, actual, title)
+	}
+
+	compare(t, pageFile("Cafe\u0301"), pageFile("Café"))
+	for _, title := range []string{"Café!", "日本 語"} {
+		if titleValidator.MatchString(title) {
+			t.Errorf("Expected %s to be an invalid title", title)
+		}
+	}
+}
+
+func TestUnicodePageRoundTrip(t *testing.T) {
+	title := "CaféNotes"
+	defer os.Remove(pageFile(title))
+
+	handler := makeHandler(saveHandler, savePath)
+	form := url.Values{"body": {"Decomposed [Cafe\u0301Notes] link"}}
+	r := httptest.NewRequest("POST", savePath+url.QueryEscape("Cafe\u0301Notes"), strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	handler(httptest.NewRecorder(), r)
+
+	p, err := loadPage(title)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compare(t, p.PrettyTitle(), "Café Notes")
+	links := wikilang.Links(string(p.Body), p.renderOptions())
+	if len(links) != 1 || links[0] != title {
+		t.Errorf("Expected a link to %s, not %v", title, links)
+	}
+
+	found := false
+	for _, page := range listPages() {
+		found = found || page == title
+	}
+	if !found {
+		t.Errorf("Expected %s to be listed", title)
+	}
+}
+
+func TestCanonicalTitleLookup(t *testing.T) {
+	defer os.Remove(pageFile("CanonicalTest"))
+	p := &Page{Title: "canonical test", Body: []byte("Text")}
+	if err := p.save(); err != nil {
+		t.Fatal(err)
+	}
+	compare(t, p.Title, "CanonicalTest")
+
+	for _, title := range []string{"CanonicalTest", "Canonical Test", "canonicaltest", "CANONICAL TEST"} {
+		p, err := loadPage(title)
+		if err != nil {
+			t.Errorf("Could not load %s: %s", title, err)
+			continue
+		}
+		compare(t, p.Title, "CanonicalTest")
+	}
+
+	for _, data := range []struct {
+		path, location string
+	}{
+		{"/view/Canonical%20Test", "/view/CanonicalTest"},
+		{"/view/canonicaltest?redirect=no", "/view/CanonicalTest?redirect=no"},
+		{"/edit/new%20page", "/edit/NewPage"},
+	} {
+		w := httptest.NewRecorder()
+		handler := makeHandler(func(http.ResponseWriter, *http.Request, string) {
+			t.Errorf("Expected %s to redirect", data.path)
+		}, data.path[:strings.Index(data.path[1:], "/")+2])
+		handler(w, httptest.NewRequest("GET", data.path, nil))
+
+		if w.Code != http.StatusMovedPermanently {
+			t.Errorf("Expected a redirect for %s, got %d", data.path, w.Code)
+		}
+		compare(t, w.Header().Get("Location"), data.location)
+	}
+
+	called := ""
+	handler := makeHandler(func(w http.ResponseWriter, r *http.Request, title string) {
+		called = title
+	}, viewPath)
+	handler(httptest.NewRecorder(), httptest.NewRequest("GET", "/view/CanonicalTest", nil))
+	compare(t, called, "CanonicalTest")
+}
+
+func TestDisplayTitles(t *testing.T) {
+	defer os.RemoveAll(dataDir + "DisplayTitleTest")
+	defer os.Remove(pageFile("DisplayTitleTest"))
+	pages := map[string]string{
+		"DisplayTitleTest":            "#title Display & Title\nParent.",
+		"DisplayTitleTest/HTTPServer": "#title The Web Server\nChild.",
+		"DisplayTitleTest/IOError":    "No display title.",
+	}
+	for title, body := range pages {
+		if err := (&Page{Title: title, Body: []byte(body)}).save(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	p, err := loadPage("DisplayTitleTest/HTTPServer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	compare(t, p.PrettyTitle(), "The Web Server")
+	compare(t, p.view().PrettyTitle(), "The Web Server")
+	if crumbs := p.Breadcrumbs(); len(crumbs) != 1 || crumbs[0] != (Breadcrumb{"DisplayTitleTest", "Display & Title"}) {
+		t.Errorf("Unexpected breadcrumbs %v", crumbs)
+	}
+	compare(t, (&Page{Title: "DisplayTitleTest/IOError"}).PrettyTitle(), "IO Error")
+
+	compare(t, wikilang.PageToHtml("[DisplayTitleTest] [./HTTPServer] [./IOError]", wikilang.Options{Root: "/", Page: "DisplayTitleTest"}),
+		"<p>\n"+
+			"  <a href=\"/view/DisplayTitleTest\">Display &amp; Title</a> <a href=\"/view/DisplayTitleTest/HTTPServer\"\n"+
+			"  >The Web Server</a> <a href=\"/view/DisplayTitleTest/IOError\">IO Error</a>\n"+
+			"</p>\n")
+
+	(&Page{Title: "DisplayTitleTest/IOError", Body: []byte("#title I/O Errors\nChild.")}).save()
+	compare(t, wikilang.PageToHtml("[DisplayTitleTest/IOError]", wikilang.Options{Root: "/"}),
+		"<p>\n  <a href=\"/view/DisplayTitleTest/IOError\">I/O Errors</a>\n</p>\n")
+}
@@ -0,0 +1,127 @@
+// Copyright (c) 2014, Daniel Gallagher
+// Use of this source code is covered by the MIT License, the full
+// text of which can be found in the LICENSE file.
+
+package wikilang
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+func init() {
+	RegisterDirective("anchor", anchorDirective)
+}
+
+// AnchorId returns the id of the anchor for a section of a page.  Ids
+// are lower case, with dashes between the words, so that a link can
+// name a section by its heading or by its id:
+//
+//	AnchorId("DocWiki Setup") => "docwiki-setup"
+//	AnchorId("docwiki-setup") => "docwiki-setup"
+func AnchorId(section string) string {
+	words := strings.FieldsFunc(NormalizeTitle(section), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.IsMark(r)
+	})
+	if len(words) == 0 {
+		return "section"
+	}
+	return strings.ToLower(strings.Join(words, "-"))
+}
+
+// SplitFragment splits the target of a wikilink into the title of the
+// page and the section of the page it links to.  The title is empty
+// for links to a section of the same page, like [#Section].
+func SplitFragment(target string) (title, section string) {
+	if i := strings.IndexByte(target, FRAGMENT_MARK); i >= 0 {
+		return target[:i], target[i+1:]
+	}
+	return target, ""
+}
+
+// anchorDirective renders [anchor:name] as a target for links to
+// [Page#name] that is not a heading.
+func anchorDirective(args string, options Options) ParseNode {
+	return TagNode{Link, map[string]string{"id": AnchorId(args)}, ParseTree{}}
+}
+
+// heading returns the text of a paragraph that is a heading: a
+// paragraph that is all bold or emphasized text, like /*Heading*/.
+func heading(n TagNode) (string, bool) {
+	if n.Tag != Paragraph {
+		return "", false
+	}
+
+	for {
+		if len(n.Tree.Nodes) != 1 {
+			return "", false
+		}
+		child, ok := n.Tree.Nodes[0].(TagNode)
+		if !ok || (child.Tag != Bold && child.Tag != Emphasis) {
+			break
+		}
+		n = child
+	}
+
+	if n.Tag == Paragraph {
+		return "", false
+	}
+	return nodeText(n.Tree), true
+}
+
+// nodeText returns all of the text in a parse tree, separated by
+// spaces.
+func nodeText(t ParseTree) string {
+	text := []string{}
+	for _, node := range t.Nodes {
+		switch node := node.(type) {
+		case TextNode:
+			text = append(text, node.Text)
+		case TagNode:
+			text = append(text, nodeText(node.Tree))
+		}
+	}
+	return strings.Join(text, " ")
+}
+
+// headingId returns the id of a heading.  Headings that would have
+// the same id as an earlier heading on the page are numbered, so that
+// every heading on a page has its own id.
+func (p *Parser) headingId(text string) string {
+	id := AnchorId(text)
+	if p.anchors == nil {
+		p.anchors = map[string]int{}
+	}
+
+	p.anchors[id]++
+	if count := p.anchors[id]; count > 1 {
+		return id + "-" + strconv.Itoa(count)
+	}
+	return id
+}
+
+// Anchors returns the ids of the headings and explicit anchors in a
+// body of wiki text, which links of the form [Page#Section] can go
+// to.
+func Anchors(body string, options Options) []string {
+	anchors := []string{}
+	for _, tree := range parse(body, options) {
+		tree.Visit(anchorVisitor{&anchors})
+	}
+	return anchors
+}
+
+type anchorVisitor struct {
+	anchors *[]string
+}
+
+func (v anchorVisitor) VisitTagBegin(n TagNode) {
+	if id, ok := n.Attributes["id"]; ok {
+		*v.anchors = append(*v.anchors, id)
+	}
+}
+
+func (v anchorVisitor) VisitTagEnd(n TagNode) {}
+
+func (v anchorVisitor) VisitText(n TextNode) {}
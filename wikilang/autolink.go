@@ -0,0 +1,44 @@
+// Copyright (c) 2014, Daniel Gallagher
+// Use of this source code is covered by the MIT License, the full
+// text of which can be found in the LICENSE file.
+
+package wikilang
+
+import (
+	"strings"
+)
+
+// AUTOLINK_ESCAPE at the start of literal text keeps the text from
+// being linked to Doxygen automatically, as in {!Widget}.
+const AUTOLINK_ESCAPE = "!"
+
+// autoLinkLiteral links a literal whose text names a Doxygen entity.
+// The entity must either be in the page's default project or be
+// unique across all projects.
+func (p *Parser) autoLinkLiteral(n TagNode) ParseNode {
+	if len(n.Tree.Nodes) != 1 {
+		return n
+	}
+	text, ok := n.Tree.Nodes[0].(TextNode)
+	if !ok {
+		return n
+	}
+
+	if strings.HasPrefix(text.Text, AUTOLINK_ESCAPE) {
+		name := text.Text[len(AUTOLINK_ESCAPE):]
+		if _, found := findEntity(p.Options.Project, name); found {
+			return TagNode{Literal, n.Attributes, ParseTree{[]ParseNode{TextNode{name}}}}
+		}
+		return n
+	}
+
+	project, found := findEntity(p.Options.Project, text.Text)
+	if !found {
+		return n
+	}
+
+	return TagNode{
+		Link,
+		wikiWordAttributes("doc:" + project + ":" + text.Text),
+		ParseTree{[]ParseNode{n}}}
+}
@@ -0,0 +1,46 @@
+// Copyright (c) 2014, Daniel Gallagher
+// Use of this source code is covered by the MIT License, the full
+// text of which can be found in the LICENSE file.
+
+package wikilang
+
+import (
+	"testing"
+)
+
+func TestAutoLink(t *testing.T) {
+	addTestProject("autoa", map[string]entity{
+		"Widget": {"class", "classWidget.html", ""},
+		"Shared": {"class", "classSharedA.html", ""},
+	})
+	addTestProject("autob", map[string]entity{
+		"Gadget": {"class", "classGadget.html", ""},
+		"Shared": {"class", "classSharedB.html", ""},
+	})
+	defer delete(projectDocs, "autoa")
+	defer delete(projectDocs, "autob")
+
+	for _, data := range [...]struct {
+		options        Options
+		data, expected string
+	}{
+		{Options{}, "{Widget}",
+			"<p>\n  <tt>Widget</tt>\n</p>\n"},
+		{Options{AutoLink: true}, "{Widget}",
+			"<p>\n  <a href=\"../doc/autoa/html/classWidget.html\" title=\"autoa\"><tt>Widget</tt></a>\n</p>\n"},
+		{Options{AutoLink: true}, "Use *{Gadget}* here",
+			"<p>\n  Use <b><a href=\"../doc/autob/html/classGadget.html\" title=\"autob\"><tt>Gadget</tt>\n  </a></b> here\n</p>\n"},
+		{Options{AutoLink: true}, "{Shared}",
+			"<p>\n  <tt>Shared</tt>\n</p>\n"},
+		{Options{Project: "autob", AutoLink: true}, "{Shared}",
+			"<p>\n  <a href=\"../doc/autob/html/classSharedB.html\" title=\"autob\"><tt>Shared</tt></a>\n</p>\n"},
+		{Options{AutoLink: true}, "{!Widget}",
+			"<p>\n  <tt>Widget</tt>\n</p>\n"},
+		{Options{AutoLink: true}, "{!= 0}",
+			"<p>\n  <tt>!= 0</tt>\n</p>\n"},
+		{Options{AutoLink: true}, "{Unknown}",
+			"<p>\n  <tt>Unknown</tt>\n</p>\n"},
+	} {
+		compareFlattenedParseTrees(t, PageToHtml(data.data, data.options), data.expected)
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright (c) 2014, Daniel Gallagher
+// Use of this source code is covered by the MIT License, the full
+// text of which can be found in the LICENSE file.
+
+package wikilang
+
+import (
+	"sort"
+)
+
+// IndexChanges lists the entities that changed between the previous
+// and current snapshots of a version of a project.
+type IndexChanges struct {
+	Added   []string
+	Removed []string
+	Moved   []EntityMove
+}
+
+// An EntityMove is an entity that changed its namespace or class, but
+// kept its unqualified name and its kind.
+type EntityMove struct {
+	From string
+	To   string
+}
+
+func init() {
+	RegisterDirective("docchanges", docChangesDirective)
+}
+
+// Changes returns the entities that changed between the previous and
+// current snapshots of a project.  If there is no previous snapshot,
+// there are no changes.
+func Changes(spec string) (IndexChanges, bool) {
+	_, _, indexer, ok := lookupIndex(spec)
+	if !ok {
+		return IndexChanges{}, false
+	}
+	indexer.wait()
+
+	indexer.mutex.RLock()
+	defer indexer.mutex.RUnlock()
+	if indexer.previous == nil {
+		return IndexChanges{[]string{}, []string{}, []EntityMove{}}, true
+	}
+
+	return diffEntities(indexer.previous, indexer.entities), true
+}
+
+// diffEntities finds the entities that were added, removed, and
+// moved.  A removed entity is paired with an added one as a move if
+// it is the only added entity with the same unqualified name and
+// kind.
+func diffEntities(before, after map[string]entity) IndexChanges {
+	added := map[string][]string{}
+	removed := []string{}
+	for name, e := range after {
+		if _, ok := before[name]; !ok {
+			key := e.kind + " " + unqualifiedName(name)
+			added[key] = append(added[key], name)
+		}
+	}
+	for name := range before {
+		if _, ok := after[name]; !ok {
+			removed = append(removed, name)
+		}
+	}
+	sort.Strings(removed)
+
+	changes := IndexChanges{[]string{}, []string{}, []EntityMove{}}
+	for _, name := range removed {
+		key := before[name].kind + " " + unqualifiedName(name)
+		if candidates := added[key]; len(candidates) == 1 {
+			changes.Moved = append(changes.Moved, EntityMove{name, candidates[0]})
+			delete(added, key)
+		} else {
+			changes.Removed = append(changes.Removed, name)
+		}
+	}
+	for _, names := range added {
+		changes.Added = append(changes.Added, names...)
+	}
+	sort.Strings(changes.Added)
+
+	return changes
+}
+
+// docChangesDirective generates the list of changes for
+// [docchanges:project].
+func docChangesDirective(spec string, options Options) ParseNode {
+	changes, ok := Changes(spec)
+	if !ok {
+		return TextNode{"(unknown project " + spec + ")"}
+	}
+	if len(changes.Added)+len(changes.Removed)+len(changes.Moved) == 0 {
+		return TextNode{"No changes between the last two snapshots of " + spec + "."}
+	}
+
+	items := []ParseNode{}
+	section := func(title string, nodes []ParseNode) {
+		if len(nodes) == 0 {
+			return
+		}
+		items = append(items, TagNode{ListItem, map[string]string{},
+			ParseTree{[]ParseNode{TextNode{title},
+				TagNode{UnorderedList, map[string]string{}, ParseTree{nodes}}}}})
+	}
+
+	nodes := []ParseNode{}
+	for _, name := range changes.Added {
+		nodes = append(nodes, listItem(Token{WikiLink, "doc:" + spec + ":" + name, 0}.ToNode()))
+	}
+	section("Added:", nodes)
+
+	nodes = []ParseNode{}
+	for _, name := range changes.Removed {
+		nodes = append(nodes, listItem(literalNode(name)))
+	}
+	section("Removed:", nodes)
+
+	nodes = []ParseNode{}
+	for _, move := range changes.Moved {
+		nodes = append(nodes, listItem(literalNode(move.From), TextNode{"moved to"},
+			Token{WikiLink, "doc:" + spec + ":" + move.To, 0}.ToNode()))
+	}
+	section("Moved:", nodes)
+
+	return TagNode{UnorderedList, map[string]string{}, ParseTree{items}}
+}
+
+func listItem(nodes ...ParseNode) ParseNode {
+	return TagNode{ListItem, map[string]string{}, ParseTree{nodes}}
+}
+
+func literalNode(text string) ParseNode {
+	return TagNode{Literal, map[string]string{}, ParseTree{[]ParseNode{TextNode{text}}}}
+}
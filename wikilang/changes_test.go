@@ -0,0 +1,74 @@
+// Copyright (c) 2014, Daniel Gallagher
+// Use of this source code is covered by the MIT License, the full
+// text of which can be found in the LICENSE file.
+
+package wikilang
+
+import (
+	"testing"
+)
+
+func TestDocChangesDirective(t *testing.T) {
+	indexer := newTestIndex(map[string]entity{
+		"Gadget":      {"class", "classGadget.html", ""},
+		"old::Window": {"class", "classold_1_1Window.html", ""},
+	})
+	indexer.update(map[string]entity{
+		"Gizmo":       {"class", "classGizmo.html", ""},
+		"gui::Window": {"class", "classgui_1_1Window.html", ""},
+	}, sourceKey{})
+	projectDocs["changes"] = &docProject{"changes", "", []string{""}, map[string]*projectIndex{"": indexer}}
+	addTestProject("unchanged", map[string]entity{})
+	defer delete(projectDocs, "changes")
+	defer delete(projectDocs, "unchanged")
+
+	compareFlattenedParseTrees(t, WikiToHtml("[docchanges:changes]"),
+		"<p>\n"+
+			"  \n"+
+			"  <ul>\n"+
+			"    \n"+
+			"    <li>\n"+
+			"      Added:\n"+
+			"      <ul>\n"+
+			"        \n"+
+			"        <li>\n"+
+			"          <a href=\"../doc/changes/html/classGizmo.html\" title=\"changes\">Gizmo</a>\n"+
+			"        </li>\n"+
+			"        \n"+
+			"      </ul>\n"+
+			"      \n"+
+			"    </li>\n"+
+			"    \n"+
+			"    <li>\n"+
+			"      Removed:\n"+
+			"      <ul>\n"+
+			"        \n"+
+			"        <li>\n"+
+			"          <tt>Gadget</tt>\n"+
+			"        </li>\n"+
+			"        \n"+
+			"      </ul>\n"+
+			"      \n"+
+			"    </li>\n"+
+			"    \n"+
+			"    <li>\n"+
+			"      Moved:\n"+
+			"      <ul>\n"+
+			"        \n"+
+			"        <li>\n"+
+			"          <tt>old::Window</tt> moved to <a href=\"../doc/changes/html/classgui_1_1Window.html\"\n"+
+			"           title=\"changes\">gui::Window</a>\n"+
+			"        </li>\n"+
+			"        \n"+
+			"      </ul>\n"+
+			"      \n"+
+			"    </li>\n"+
+			"    \n"+
+			"  </ul>\n"+
+			"  \n"+
+			"</p>\n")
+	compareFlattenedParseTrees(t, WikiToHtml("[docchanges:unchanged]"),
+		"<p>\n  No changes between the last two snapshots of unchanged.\n</p>\n")
+	compareFlattenedParseTrees(t, WikiToHtml("[docchanges:missing]"),
+		"<p>\n  (unknown project missing)\n</p>\n")
+}
@@ -0,0 +1,134 @@
+// Copyright (c) 2014, Daniel Gallagher
+// Use of this source code is covered by the MIT License, the full
+// text of which can be found in the LICENSE file.
+
+package wikilang
+
+import (
+	"sort"
+	"strings"
+)
+
+// A completionKey maps a lower case search key to the entity it was
+// generated from.  Each entity has a key for its full name and, if it
+// is qualified (e.g., Namespace::Class), a key for its last
+// component.
+type completionKey struct {
+	key  string
+	name string
+}
+
+// completionKeys is kept sorted by key so that all keys with a given
+// prefix are adjacent and can be found with a binary search.
+type completionKeys []completionKey
+
+func newCompletionKeys(entities map[string]entity) completionKeys {
+	keys := completionKeys{}
+	for name := range entities {
+		keys = append(keys, completionKey{strings.ToLower(name), name})
+		if short := unqualifiedName(name); short != name {
+			keys = append(keys, completionKey{strings.ToLower(short), name})
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].key != keys[j].key {
+			return keys[i].key < keys[j].key
+		}
+		return keys[i].name < keys[j].name
+	})
+
+	return keys
+}
+
+// withPrefix returns the names of all entities that have a key
+// starting with prefix.  Each name is returned only once.
+func (keys completionKeys) withPrefix(prefix string) []string {
+	prefix = strings.ToLower(prefix)
+	start := sort.Search(len(keys), func(i int) bool {
+		return keys[i].key >= prefix
+	})
+
+	seen := map[string]bool{}
+	names := []string{}
+	for i := start; i < len(keys) && strings.HasPrefix(keys[i].key, prefix); i++ {
+		if !seen[keys[i].name] {
+			seen[keys[i].name] = true
+			names = append(names, keys[i].name)
+		}
+	}
+
+	return names
+}
+
+// unqualifiedName strips any namespace or class qualification from a
+// Doxygen name.
+//
+//	unqualifiedName("Namespace::Class::method") => "method"
+func unqualifiedName(name string) string {
+	if i := strings.LastIndex(name, "::"); i >= 0 {
+		return name[i+2:]
+	}
+	return name
+}
+
+// completionRank orders a name matching prefix.  Lower ranks are
+// better matches: exact matches come first, then names that start
+// with the prefix, then names whose unqualified part starts with it.
+func completionRank(name, prefix string) int {
+	switch {
+	case name == prefix:
+		return 0
+	case strings.EqualFold(name, prefix), strings.EqualFold(unqualifiedName(name), prefix):
+		return 1
+	case strings.HasPrefix(name, prefix):
+		return 2
+	case strings.HasPrefix(strings.ToLower(name), strings.ToLower(prefix)):
+		return 3
+	}
+	return 4
+}
+
+// RankCompletions sorts names that match prefix from best to worst
+// match.  Names with the same rank are sorted by length, then
+// alphabetically, so that shorter, more general names come first.
+func RankCompletions(names []string, prefix string) {
+	sort.Slice(names, func(i, j int) bool {
+		ri, rj := completionRank(names[i], prefix), completionRank(names[j], prefix)
+		if ri != rj {
+			return ri < rj
+		}
+		if len(names[i]) != len(names[j]) {
+			return len(names[i]) < len(names[j])
+		}
+		return names[i] < names[j]
+	})
+}
+
+// CompleteDocLink returns up to limit suggestions for entities in
+// project whose names, or unqualified names, start with prefix.  The
+// project may name a specific version, as in project@1.2.  The
+// suggestions are ranked from best to worst match.  If the project
+// does not exist, there are no suggestions.
+func CompleteDocLink(spec, prefix string, limit int) []Entity {
+	suggestions := []Entity{}
+
+	project, version, indexer, ok := lookupIndex(spec)
+	if !ok {
+		return suggestions
+	}
+	entities, keys := indexer.current()
+
+	names := keys.withPrefix(prefix)
+	RankCompletions(names, prefix)
+	if len(names) > limit {
+		names = names[:limit]
+	}
+
+	for _, name := range names {
+		e := entities[name]
+		suggestions = append(suggestions, e.export(name, project, version))
+	}
+
+	return suggestions
+}
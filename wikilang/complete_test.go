@@ -0,0 +1,64 @@
+// Copyright (c) 2014, Daniel Gallagher
+// Use of this source code is covered by the MIT License, the full
+// text of which can be found in the LICENSE file.
+
+package wikilang
+
+import (
+	"strings"
+	"testing"
+)
+
+func newTestIndex(entities map[string]entity) *projectIndex {
+	indexer := &projectIndex{notifier: make(chan bool)}
+	indexer.update(entities, sourceKey{})
+	close(indexer.notifier)
+	return indexer
+}
+
+func addTestProject(name string, entities map[string]entity) {
+	projectDocs[name] = &docProject{name, "", []string{""},
+		map[string]*projectIndex{"": newTestIndex(entities)}}
+}
+
+func TestCompleteDocLink(t *testing.T) {
+	addTestProject("complete", map[string]entity{
+		"Widget":                {"class", "classWidget.html", ""},
+		"WidgetFactory":         {"class", "classWidgetFactory.html", ""},
+		"gui::Window":           {"class", "classgui_1_1Window.html", ""},
+		"gui::Window::widget":   {"function", "classgui_1_1Window.html#a1", ""},
+		"gui::Window::position": {"function", "classgui_1_1Window.html#a2", ""},
+		"widgetCount":           {"variable", "globals.html#a3", ""},
+	})
+	defer delete(projectDocs, "complete")
+
+	for _, data := range [...]struct {
+		prefix   string
+		limit    int
+		expected string
+	}{
+		{"Widget", 10, "Widget gui::Window::widget WidgetFactory widgetCount"},
+		{"widget", 10, "Widget gui::Window::widget widgetCount WidgetFactory"},
+		{"Widget", 2, "Widget gui::Window::widget"},
+		{"gui::", 10, "gui::Window gui::Window::widget gui::Window::position"},
+		{"Win", 10, "gui::Window"},
+		{"pos", 10, "gui::Window::position"},
+		{"nothing", 10, ""},
+	} {
+		names := []string{}
+		for _, s := range CompleteDocLink("complete", data.prefix, data.limit) {
+			names = append(names, s.Name)
+		}
+
+		compareFlattenedParseTrees(t, strings.Join(names, " "), data.expected)
+	}
+
+	if s := CompleteDocLink("missing", "Widget", 10); len(s) != 0 {
+		t.Errorf("Expected no suggestions for a missing project, got %v", s)
+	}
+
+	s := CompleteDocLink("complete", "WidgetF", 10)
+	if len(s) != 1 || s[0].Kind != "class" || s[0].Url != "../doc/complete/html/classWidgetFactory.html" {
+		t.Errorf("Unexpected suggestion %v", s)
+	}
+}
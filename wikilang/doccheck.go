@@ -0,0 +1,113 @@
+// Copyright (c) 2014, Daniel Gallagher
+// Use of this source code is covered by the MIT License, the full
+// text of which can be found in the LICENSE file.
+
+package wikilang
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// These are the possible results of checking a doclink.
+const (
+	DocLinkOk              = "ok"                // The entity exists
+	DocLinkRemoved         = "removed"           // The entity does not exist
+	DocLinkRenameCandidate = "renamed-candidate" // The entity may have moved
+	DocLinkNoProject       = "unknown-project"   // The project does not exist
+)
+
+var indexChangedFunc struct {
+	sync.Mutex
+	f func(spec string)
+}
+
+// SetIndexChanged sets the function that is called when a project's
+// entities change because its search data was read again, as when its
+// Doxygen is regenerated.  It is given the project's name, as in
+// project@version, and is called on its own goroutine.
+func SetIndexChanged(f func(spec string)) {
+	indexChangedFunc.Lock()
+	defer indexChangedFunc.Unlock()
+	indexChangedFunc.f = f
+}
+
+// indexChanged returns the function set by SetIndexChanged.
+func indexChanged() func(spec string) {
+	indexChangedFunc.Lock()
+	defer indexChangedFunc.Unlock()
+	if indexChangedFunc.f == nil {
+		return func(string) {}
+	}
+	return indexChangedFunc.f
+}
+
+// Projects returns the names of all projects in the project index,
+// sorted alphabetically.
+func Projects() []string {
+	names := []string{}
+	for name := range projectDocs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Reindex rereads the search data for a project specification, which
+// is either a project name or project@version.  The search data is
+// only read if it has changed.  If the entities changed, the old ones
+// become the previous snapshot, and the function set by
+// SetIndexChanged is called.  It returns the changes between the
+// previous snapshot and the new entities.  If the search data cannot
+// be read, the old entities are kept.
+func Reindex(spec string) (IndexChanges, error) {
+	_, _, indexer, ok := lookupIndex(spec)
+	if !ok {
+		return IndexChanges{}, fmt.Errorf("Unknown project %s", spec)
+	}
+	indexer.wait()
+
+	indexer.mutex.RLock()
+	source := indexer.source
+	indexer.mutex.RUnlock()
+
+	if _, ok := matchesSource(indexer.searchData, source); !ok {
+		if err := indexer.reread(time.Now()); err != nil {
+			return IndexChanges{}, err
+		}
+	}
+
+	changes, _ := Changes(spec)
+	return changes, nil
+}
+
+// CheckDocLink checks whether a doclink's entity exists in the
+// project.  If it does not, but there are entities with the same
+// unqualified name (as when a class moves to a new namespace), the
+// link is a rename candidate and those entities are returned.
+func CheckDocLink(spec, name string) (status string, candidates []string) {
+	_, _, indexer, ok := lookupIndex(spec)
+	if !ok {
+		return DocLinkNoProject, nil
+	}
+
+	entities, keys := indexer.current()
+	if _, found := entities[name]; found {
+		return DocLinkOk, nil
+	}
+
+	short := unqualifiedName(name)
+	for _, candidate := range keys.withPrefix(short) {
+		if unqualifiedName(candidate) == short {
+			candidates = append(candidates, candidate)
+		}
+	}
+
+	if len(candidates) > 0 {
+		return DocLinkRenameCandidate, candidates
+	}
+	return DocLinkRemoved, nil
+}
@@ -0,0 +1,122 @@
+// Copyright (c) 2014, Daniel Gallagher
+// Use of this source code is covered by the MIT License, the full
+// text of which can be found in the LICENSE file.
+
+package wikilang
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeSearchData(t *testing.T, file string, names ...string) {
+	data := "<?xml version=\"1.0\"?>\n<add>\n"
+	for _, name := range names {
+		data += "<doc>\n" +
+			"  <field name=\"type\">class</field>\n" +
+			"  <field name=\"name\">" + name + "</field>\n" +
+			"  <field name=\"url\">class" + strings.Replace(name, "::", "_1_1", -1) + ".html</field>\n" +
+			"  <field name=\"text\">The " + name + " class &amp; friends.  More\n details.</field>\n" +
+			"</doc>\n"
+	}
+	data += "</add>\n"
+
+	if err := ioutil.WriteFile(file, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReindex(t *testing.T) {
+	dir, err := ioutil.TempDir("", "docwiki")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "searchData.xml")
+	writeSearchData(t, file, "Widget", "Gadget", "old::Window")
+
+	reindexed := make(chan string, 10)
+	SetIndexChanged(func(spec string) {
+		reindexed <- spec
+	})
+	defer SetIndexChanged(nil)
+
+	indexer := &projectIndex{spec: "reindex", searchData: file, notifier: make(chan bool)}
+	projectDocs["reindex"] = &docProject{"reindex", "", []string{""}, map[string]*projectIndex{"": indexer}}
+	defer delete(projectDocs, "reindex")
+	indexer.index()
+
+	if changes, ok := Changes("reindex"); !ok || len(changes.Added)+len(changes.Removed) != 0 {
+		t.Errorf("Unexpected changes before reindexing: %v", changes)
+	}
+
+	writeSearchData(t, file, "Widget", "Gizmo", "gui::Window")
+	changes, err := Reindex("reindex")
+	if err != nil {
+		t.Fatal(err)
+	}
+	compareFlattenedParseTrees(t, strings.Join(changes.Added, " "), "Gizmo")
+	compareFlattenedParseTrees(t, strings.Join(changes.Removed, " "), "Gadget")
+	if len(changes.Moved) != 1 || changes.Moved[0] != (EntityMove{"old::Window", "gui::Window"}) {
+		t.Errorf("Unexpected moves %v", changes.Moved)
+	}
+
+	// Only reindexing that changes loaded entities is reported.
+	select {
+	case spec := <-reindexed:
+		compareFlattenedParseTrees(t, spec, "reindex")
+	case <-time.After(5 * time.Second):
+		t.Errorf("Expected the changed entities to be reported")
+	}
+
+	// Reindexing without changes keeps the previous snapshot.
+	if changes, err = Reindex("reindex"); err != nil || len(changes.Added) != 1 {
+		t.Errorf("Unexpected changes %v (%v) after reindexing the same data", changes, err)
+	}
+	if len(reindexed) != 0 {
+		t.Errorf("Unexpected report of reindexing the same data")
+	}
+
+	// The snapshots are used after a restart.
+	restarted := &projectIndex{searchData: file, notifier: make(chan bool)}
+	projectDocs["reindex"].indexes[""] = restarted
+	restarted.index()
+	if changes, _ = Changes("reindex"); len(changes.Added) != 1 || len(changes.Removed) != 1 {
+		t.Errorf("Unexpected changes %v after restarting", changes)
+	}
+
+	for _, data := range [...]struct {
+		project, entity, status, candidates string
+	}{
+		{"reindex", "Widget", DocLinkOk, ""},
+		{"reindex", "Gadget", DocLinkRemoved, ""},
+		{"reindex", "old::Window", DocLinkRenameCandidate, "gui::Window"},
+		{"missing", "Widget", DocLinkNoProject, ""},
+	} {
+		status, candidates := CheckDocLink(data.project, data.entity)
+		compareFlattenedParseTrees(t, status, data.status)
+		compareFlattenedParseTrees(t, strings.Join(candidates, " "), data.candidates)
+	}
+
+	e, ok := LookupEntity("reindex", "gui::Window")
+	if !ok || e.Kind != "class" || e.Brief != "The gui::Window class & friends." ||
+		e.Url != "../doc/reindex/html/classgui_1_1Window.html" {
+		t.Errorf("Unexpected entity %v", e)
+	}
+	if _, ok := LookupEntity("reindex", "Gadget"); ok {
+		t.Errorf("Unexpected entity Gadget after reindexing")
+	}
+
+	os.Remove(file)
+	if _, err := Reindex("reindex"); err == nil {
+		t.Errorf("Expected an error reindexing a missing file")
+	}
+	if status, _ := CheckDocLink("reindex", "Widget"); status != DocLinkOk {
+		t.Errorf("Expected the old index to be kept after a failed reindex")
+	}
+}
@@ -7,25 +7,112 @@ package wikilang
 import (
 	"encoding/xml"
 	"fmt"
+	"html"
 	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 )
 
 const projectIndexFile = "projectIndex.xml"
 
+// VersionSeparator separates a project name from a version name in
+// doclinks and /doc/ URLs, e.g., [doc:project@1.2:entity].
+const VersionSeparator = "@"
+
+// A docProject is a project from the project index.  Each project
+// has at least one version.  Projects that do not declare any
+// versions have a single version with an empty name.
+type docProject struct {
+	name           string
+	defaultVersion string
+	versions       []string // Version names in the order declared
+	indexes        map[string]*projectIndex
+}
+
+// A projectIndex contains the Doxygen search data for one version of
+// a project.  The entities may be replaced when the project is
+// reindexed, so they must only be read through current().
 type projectIndex struct {
-	urls     map[string]string
-	done     bool
-	notifier chan bool
+	spec       string // The project and version, as in project@version
+	searchData string
+	htmlRoot   string
+	xmlRoot    string // Doxygen XML output, for member tables
+	sourceRoot string // Checkout of the project's source, for snippets
+	notifier   chan bool
+
+	mutex    sync.RWMutex
+	entities map[string]entity
+	keys     completionKeys
+	previous map[string]entity // Entities before the search data last changed
+	source   sourceKey         // Identifies the search data the entities came from
+
+	state    string        // One of the Index* states
+	lastErr  error         // Error from the last time the search data was read
+	loadTime time.Duration // Time it took to load the entities
+	loadedAt time.Time     // When the entities were loaded
+}
+
+// An Entity describes a single item in a project's Doxygen.  The
+// fields are tagged so that the wiki can return them as JSON.
+type Entity struct {
+	Name  string `json:"name"`            // Full Doxygen name of the entity
+	Kind  string `json:"kind"`            // Doxygen type of the entity, e.g., class
+	Url   string `json:"url"`             // Link to the entity's documentation
+	Brief string `json:"brief,omitempty"` // First sentence of the entity's documentation
 }
 
-var projectDocs map[string]*projectIndex
+// An entity is a single item in a project's Doxygen, such as a class
+// or a function.
+type entity struct {
+	kind  string // Doxygen type of the entity, e.g., class
+	url   string // URL relative to the project's HTML root
+	brief string // First sentence of the entity's documentation
+}
+
+// export converts an entity in a version of a project to an Entity.
+func (e entity) export(name string, project *docProject, version string) Entity {
+	return Entity{name, e.kind, docUrl(DocUrlName(project.name, version), e.url), e.brief}
+}
+
+// LookupEntity finds an entity in a project specification, which is
+// either a project name or project@version.
+func LookupEntity(spec, name string) (Entity, bool) {
+	project, version, indexer, ok := lookupIndex(spec)
+	if !ok {
+		return Entity{}, false
+	}
+
+	entities, _ := indexer.current()
+	e, ok := entities[name]
+	if !ok {
+		return Entity{}, false
+	}
+	return e.export(name, project, version), true
+}
+
+var projectDocs map[string]*docProject
 
 func init() {
-	projectDocs = make(map[string]*projectIndex)
+	projectDocs = make(map[string]*docProject)
 
-	type Project struct {
+	type Version struct {
 		Name       string `xml:"name,attr"`
 		SearchData string `xml:"searchdata"`
+		Html       string `xml:"html"`
+		Xml        string `xml:"xml"`
+		Source     string `xml:"source"`
+	}
+	type Project struct {
+		Name       string    `xml:"name,attr"`
+		Default    string    `xml:"default,attr"`
+		SearchData string    `xml:"searchdata"`
+		Html       string    `xml:"html"`
+		Xml        string    `xml:"xml"`
+		Source     string    `xml:"source"`
+		Versions   []Version `xml:"version"`
 	}
 	type Result struct {
 		Project []Project `xml:"project"`
@@ -42,33 +129,310 @@ func init() {
 	}
 
 	for _, project := range result.Project {
-		indexer := &projectIndex{map[string]string{}, false, make(chan bool)}
-		projectDocs[project.Name] = indexer
-		go indexer.index(project.SearchData)
+		if len(project.Versions) == 0 {
+			project.Versions = []Version{{"", project.SearchData, project.Html, project.Xml, project.Source}}
+		}
+
+		p := &docProject{project.Name, project.Default, []string{}, map[string]*projectIndex{}}
+		for _, version := range project.Versions {
+			htmlRoot := version.Html
+			if htmlRoot == "" {
+				htmlRoot = "doc/" + DocUrlName(project.Name, version.Name) + "/html"
+			}
+			xmlRoot := version.Xml
+			if xmlRoot == "" {
+				xmlRoot = "doc/" + DocUrlName(project.Name, version.Name) + "/xml"
+			}
+
+			indexer := &projectIndex{spec: DocUrlName(project.Name, version.Name), searchData: version.SearchData,
+				htmlRoot: htmlRoot, xmlRoot: xmlRoot, sourceRoot: version.Source, notifier: make(chan bool)}
+			p.versions = append(p.versions, version.Name)
+			p.indexes[version.Name] = indexer
+			go indexer.index()
+		}
+
+		if _, ok := p.indexes[p.defaultVersion]; !ok {
+			p.defaultVersion = p.versions[len(p.versions)-1]
+		}
+
+		projectDocs[project.Name] = p
+	}
+}
+
+// SplitVersion splits a project specification of the form
+// project@version into its parts.  The version is empty if it is not
+// specified.
+func SplitVersion(spec string) (project, version string) {
+	parts := strings.SplitN(spec, VersionSeparator, 2)
+	if len(parts) == 1 {
+		return parts[0], ""
+	}
+	return parts[0], parts[1]
+}
+
+// lookupIndex finds the index for a project specification.  If no
+// version is specified, the project's default version is used.
+func lookupIndex(spec string) (*docProject, string, *projectIndex, bool) {
+	name, version := SplitVersion(spec)
+	project, ok := projectDocs[name]
+	if !ok {
+		return nil, "", nil, false
 	}
+
+	if version == "" {
+		version = project.defaultVersion
+	}
+
+	indexer, ok := project.indexes[version]
+	return project, version, indexer, ok
 }
 
 // DocLink searches the indexed project to find the URL for a
 // particular entity in the project's Doxygen.  The entity can be
 // anything that Doxygen provides a link to, such as classes, methods,
-// functions, types, etc.
+// functions, types, etc.  The project may name a specific version,
+// as in project@1.2; otherwise the project's default version is used.
 //
 // If the project or entity does not exist, the URL will be to the
 // project's Doxygen index.  It may or may not exist.
-func DocLink(project, entity string) string {
+func DocLink(spec, entity string) string {
 	url := "index.html"
-	indexer, ok := projectDocs[project]
+	project, version, indexer, ok := lookupIndex(spec)
 	if ok {
-		if !indexer.done {
-			indexer.done = <-indexer.notifier
+		entities, _ := indexer.current()
+		if e, found := entities[entity]; found {
+			url = e.url
 		}
-		url, _ = indexer.urls[entity]
+		spec = DocUrlName(project.name, version)
 	}
 
-	return "../doc/" + project + "/html/" + url
+	return docUrl(spec, url)
 }
 
-func (indexer *projectIndex) index(searchData string) {
+// findEntity finds the project that documents an entity.  The entity
+// is looked up in the default project first; failing that, it must
+// appear in exactly one project to be found.  The project returned is
+// suitable for use in a doclink.
+func findEntity(defaultProject, name string) (string, bool) {
+	if _, _, indexer, ok := lookupIndex(defaultProject); ok {
+		entities, _ := indexer.current()
+		if _, found := entities[name]; found {
+			return defaultProject, true
+		}
+	}
+
+	found := ""
+	count := 0
+	for project := range projectDocs {
+		_, _, indexer, _ := lookupIndex(project)
+		entities, _ := indexer.current()
+		if _, ok := entities[name]; ok {
+			found = project
+			count++
+		}
+	}
+
+	return found, count == 1
+}
+
+// DocUrlName returns the name of the directory under /doc/ that
+// serves the given version of a project's HTML.
+func DocUrlName(project, version string) string {
+	if version == "" {
+		return project
+	}
+	return project + VersionSeparator + version
+}
+
+func docUrl(name, url string) string {
+	return relativeRoot + "doc/" + name + "/html/" + url
+}
+
+// DocRoot returns the directory containing the Doxygen HTML for a
+// project specification, which is either a project name or
+// project@version.
+func DocRoot(spec string) (string, bool) {
+	_, _, indexer, ok := lookupIndex(spec)
+	if !ok {
+		return "", false
+	}
+	return indexer.htmlRoot, true
+}
+
+// DocVersions returns the versions of a project in the order they
+// are declared in the project index, along with the default version.
+// Projects without versions have a single, empty version.
+func DocVersions(project string) (versions []string, defaultVersion string, ok bool) {
+	p, ok := projectDocs[project]
+	if !ok {
+		return nil, "", false
+	}
+	return p.versions, p.defaultVersion, true
+}
+
+// Entities returns all of the entities in a project specification,
+// which is either a project name or project@version, sorted by name.
+func Entities(spec string) []Entity {
+	result := []Entity{}
+
+	project, version, indexer, ok := lookupIndex(spec)
+	if !ok {
+		return result
+	}
+
+	entities, _ := indexer.current()
+	for name, e := range entities {
+		result = append(result, e.export(name, project, version))
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+
+	return result
+}
+
+// wait blocks until the project has been indexed.  Any number of
+// callers may wait at the same time.
+func (indexer *projectIndex) wait() {
+	<-indexer.notifier
+}
+
+// current waits for the project to be indexed, then returns its
+// entities and completion keys.  Neither may be modified.
+func (indexer *projectIndex) current() (map[string]entity, completionKeys) {
+	indexer.wait()
+
+	indexer.mutex.RLock()
+	defer indexer.mutex.RUnlock()
+	return indexer.entities, indexer.keys
+}
+
+// update replaces the project's entities.  If they are different
+// from the old ones, the old ones become the previous snapshot.  It
+// returns whether the entities changed.
+func (indexer *projectIndex) update(entities map[string]entity, source sourceKey) bool {
+	keys := newCompletionKeys(entities)
+
+	indexer.mutex.Lock()
+	defer indexer.mutex.Unlock()
+	changed := !sameEntities(indexer.entities, entities)
+	if changed && indexer.entities != nil {
+		indexer.previous = indexer.entities
+	}
+	indexer.entities = entities
+	indexer.keys = keys
+	indexer.source = source
+
+	return changed
+}
+
+// index loads the project's entities for the first time.  If the
+// current snapshot was made from the same search data, it is used
+// as is.  If the search data has changed since, the old snapshot is
+// used until the search data has been read again, so that doclinks
+// do not wait for large projects to be parsed.  The search data is
+// only read before the project is ready if there is no snapshot.
+//
+// If the search data cannot be read, the project has no entities, and
+// its status says why.
+func (indexer *projectIndex) index() {
+	started := time.Now()
+
+	saved, savedSource, err := readSnapshot(indexer.searchData + currentSnapshot)
+	previous, _, _ := readSnapshot(indexer.searchData + previousSnapshot)
+
+	var keys completionKeys
+	if err == nil {
+		keys = newCompletionKeys(saved)
+	}
+
+	indexer.mutex.Lock()
+	indexer.previous = previous
+	if err == nil {
+		indexer.entities = saved
+		indexer.keys = keys
+		indexer.source = savedSource
+	}
+	indexer.mutex.Unlock()
+
+	if err == nil {
+		if source, ok := matchesSource(indexer.searchData, savedSource); ok {
+			indexer.setState(IndexReady, nil, started)
+			close(indexer.notifier)
+			if source != savedSource {
+				indexer.update(saved, source)
+				indexer.saveSnapshots()
+			}
+			return
+		}
+
+		indexer.setState(IndexRefreshing, nil, started)
+		close(indexer.notifier)
+		indexer.reread(started)
+		return
+	}
+
+	if err := indexer.reread(started); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		indexer.update(map[string]entity{}, sourceKey{})
+	}
+	close(indexer.notifier)
+}
+
+// reread reads the project's search data and saves the snapshots.  If
+// the search data cannot be read, the old entities are kept.  If the
+// search data replaced entities that were already loaded, the wiki is
+// told that the project's entities changed.
+func (indexer *projectIndex) reread(started time.Time) error {
+	entities, source, err := readSearchData(indexer.searchData)
+
+	indexer.mutex.RLock()
+	loaded := indexer.entities != nil
+	indexer.mutex.RUnlock()
+
+	if err != nil {
+		state := IndexFailed
+		if loaded {
+			state = IndexStale
+		}
+		indexer.setState(state, err, started)
+		return err
+	}
+
+	changed := indexer.update(entities, source)
+	indexer.setState(IndexReady, nil, started)
+	indexer.saveSnapshots()
+	if changed && loaded {
+		go indexChanged()(indexer.spec)
+	}
+	return nil
+}
+
+func (indexer *projectIndex) setState(state string, err error, started time.Time) {
+	indexer.mutex.Lock()
+	defer indexer.mutex.Unlock()
+
+	indexer.state = state
+	indexer.lastErr = err
+	if err == nil {
+		indexer.loadTime = time.Since(started)
+		indexer.loadedAt = time.Now()
+	}
+}
+
+// firstSentence returns the first sentence of Doxygen's text for an
+// entity, which is usually its brief description.
+func firstSentence(text string) string {
+	text = strings.Join(strings.Fields(html.UnescapeString(text)), " ")
+	if i := strings.Index(text, ". "); i >= 0 {
+		text = text[:i+1]
+	}
+	return text
+}
+
+// readSearchData reads the entities from a Doxygen search data file.
+// It also returns the key that identifies the file's contents.
+func readSearchData(searchData string) (map[string]entity, sourceKey, error) {
 	type Field struct {
 		Name  string `xml:"name,attr"`
 		Value string `xml:",innerxml"`
@@ -80,31 +444,44 @@ func (indexer *projectIndex) index(searchData string) {
 		Docs []Doc `xml:"doc"`
 	}
 
+	source, err := statSource(searchData)
+	if err != nil {
+		return nil, sourceKey{}, fmt.Errorf("Could not read %s", searchData)
+	}
+
 	data, err := ioutil.ReadFile(searchData)
 	if err != nil {
-		panic(fmt.Sprintf("Could not read %s", searchData))
+		return nil, sourceKey{}, fmt.Errorf("Could not read %s", searchData)
 	}
+	source.Size = int64(len(data))
+	source.Hash = hashData(data)
 
 	var result Result
 	if err = xml.Unmarshal(data, &result); err != nil {
-		panic(fmt.Sprintf("Could not read %s: %s", searchData, err))
+		return nil, sourceKey{}, fmt.Errorf("Could not read %s: %s", searchData, err)
 	}
 
+	entities := map[string]entity{}
 	for _, doc := range result.Docs {
 		name := ""
-		url := ""
+		e := entity{}
 		for _, field := range doc.Fields {
-			if field.Name == "name" {
+			switch field.Name {
+			case "name":
 				name = field.Value
-			} else if field.Name == "url" {
-				url = field.Value
+			case "type":
+				e.kind = field.Value
+			case "url":
+				e.url = field.Value
+			case "text":
+				e.brief = firstSentence(field.Value)
 			}
 		}
 
-		if len(name) > 0 && len(url) > 0 {
-			indexer.urls[name] = url
+		if len(name) > 0 && len(e.url) > 0 {
+			entities[name] = e
 		}
 	}
 
-	indexer.notifier <- true
+	return entities, source, nil
 }
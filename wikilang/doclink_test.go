@@ -14,7 +14,23 @@ func TestWikiCase(t *testing.T) {
 		{data: "wrongStart", expected: "wrong Start"},
 		{data: "LotsOfWords", expected: "Lots Of Words"},
 		{data: "Existing Spacing", expected: "Existing Spacing"},
-		{data: "Mixed SpacingWords", expected: "Mixed Spacing Words"}} {
+		{data: "Mixed SpacingWords", expected: "Mixed Spacing Words"},
+		{data: "ÜberÄrger", expected: "Über Ärger"},
+		{data: "ΚαλήΜέρα", expected: "Καλή Μέρα"},
+		{data: "日本語", expected: "日本語"},
+		{data: "Widget日本語Notes", expected: "Widget 日本語 Notes"},
+		{data: "हिन्दी", expected: "हिन्दी"},
+		{data: "Version2", expected: "Version2"},
+		{data: "HTTPServerConfig", expected: "HTTP Server Config"},
+		{data: "ParseXML", expected: "Parse XML"},
+		{data: "XMLHttpRequest", expected: "XML Http Request"},
+		{data: "Top10Lists", expected: "Top10 Lists"},
+		{data: "HTTP2Server", expected: "HTTP2 Server"},
+		{data: "Base64Encoding2", expected: "Base64 Encoding2"},
+		{data: "IOError", expected: "IO Error"},
+		{data: "ABC", expected: "ABC"},
+		{data: "A", expected: "A"},
+		{data: "ÜBERGröße", expected: "ÜBER Größe"}} {
 
 		if actual := WikiCase(data.data); actual != data.expected {
 			t.Errorf("  Expected: \"%s\" (%d)", data.expected, len(data.expected))
@@ -22,3 +38,83 @@ func TestWikiCase(t *testing.T) {
 		}
 	}
 }
+
+func TestCanonicalTitle(t *testing.T) {
+	for _, data := range [...]struct {
+		data, expected string
+	}{
+		{"FrontPage", "FrontPage"},
+		{"Front Page", "FrontPage"},
+		{"front page", "FrontPage"},
+		{"frontPage", "FrontPage"},
+		{"Multiple\nLines", "MultipleLines"},
+		{"  Extra   Spaces ", "ExtraSpaces"},
+		{"design notes/thread model", "DesignNotes/ThreadModel"},
+		{"über ärger", "ÜberÄrger"},
+		{"日本語 設計", "日本語設計"},
+	} {
+		if actual := CanonicalTitle(data.data); actual != data.expected {
+			t.Errorf("CanonicalTitle(%q) = %q, expected %q", data.data, actual, data.expected)
+		}
+	}
+}
+
+func addVersionedTestProject(name, defaultVersion string, versions map[string]map[string]entity) {
+	p := &docProject{name, defaultVersion, []string{}, map[string]*projectIndex{}}
+	for _, version := range []string{"1.0", "2.0"} {
+		indexer := newTestIndex(versions[version])
+		indexer.htmlRoot = "doc/" + name + "-" + version + "/html"
+		p.versions = append(p.versions, version)
+		p.indexes[version] = indexer
+	}
+	projectDocs[name] = p
+}
+
+func TestVersionedDocLink(t *testing.T) {
+	addVersionedTestProject("versioned", "1.0", map[string]map[string]entity{
+		"1.0": {"Old": {"class", "classOld.html", ""}, "Both": {"class", "classBoth.html", ""}},
+		"2.0": {"New": {"class", "classNew.html", ""}, "Both": {"class", "classBoth2.html", ""}},
+	})
+	defer delete(projectDocs, "versioned")
+
+	for _, data := range [...]struct {
+		spec, entity, expected string
+	}{
+		{"versioned", "Old", "../doc/versioned@1.0/html/classOld.html"},
+		{"versioned", "New", "../doc/versioned@1.0/html/index.html"},
+		{"versioned@1.0", "Both", "../doc/versioned@1.0/html/classBoth.html"},
+		{"versioned@2.0", "Both", "../doc/versioned@2.0/html/classBoth2.html"},
+		{"versioned@3.0", "Both", "../doc/versioned@3.0/html/index.html"},
+		{"missing", "Both", "../doc/missing/html/index.html"},
+	} {
+		compareFlattenedParseTrees(t, DocLink(data.spec, data.entity), data.expected)
+	}
+
+	if root, ok := DocRoot("versioned@2.0"); !ok || root != "doc/versioned-2.0/html" {
+		t.Errorf("Unexpected root %s for versioned@2.0", root)
+	}
+	if root, ok := DocRoot("versioned"); !ok || root != "doc/versioned-1.0/html" {
+		t.Errorf("Unexpected root %s for versioned", root)
+	}
+	if _, ok := DocRoot("versioned@3.0"); ok {
+		t.Errorf("Unexpected root for versioned@3.0")
+	}
+}
+
+func TestDefaultProject(t *testing.T) {
+	addTestProject("big", map[string]entity{
+		"Widget": {"class", "classWidget.html", ""},
+	})
+	defer delete(projectDocs, "big")
+
+	for _, data := range [...]struct {
+		project, data, expected string
+	}{
+		{"big", "[doc::Widget]", "<p>\n  <a href=\"../doc/big/html/classWidget.html\" title=\"big\">Widget</a>\n</p>\n"},
+		{"other", "[doc:big:Widget]", "<p>\n  <a href=\"../doc/big/html/classWidget.html\" title=\"big\">Widget</a>\n</p>\n"},
+		{"", "[doc::Widget]", "<p>\n  <a href=\"../doc//html/index.html\" title=\"\">Widget</a>\n</p>\n"},
+		{"big", "[the widget|doc::Widget]", "<p>\n  <a href=\"../doc/big/html/classWidget.html\" title=\"big\">the widget</a>\n</p>\n"},
+	} {
+		compareFlattenedParseTrees(t, PageToHtml(data.data, Options{Project: data.project}), data.expected)
+	}
+}
@@ -11,7 +11,7 @@ import (
 	"unicode"
 )
 
-const paragraphTags = ",p,pre,ul,li,ol,"
+const paragraphTags = ",p,pre,ul,li,ol,table,tr,div,"
 
 // A HtmlGen converts wiki a set of ParseTrees to HTML output.
 type HtmlGen struct {
@@ -73,8 +73,8 @@ func (v *htmlGenVisitor) VisitTagBegin(n TagNode) {
 	}
 
 	fmt.Fprintf(v.writer, "<%s", n.Tag)
-	for key, value := range n.Attributes {
-		fmt.Fprintf(v.writer, " %s=\"%s\"", key, value)
+	for _, key := range n.attributeNames() {
+		fmt.Fprintf(v.writer, " %s=\"%s\"", key, n.Attributes[key])
 	}
 	fmt.Fprintf(v.writer, ">")
 	if isParagraph {
@@ -0,0 +1,88 @@
+// Copyright (c) 2014, Daniel Gallagher
+// Use of this source code is covered by the MIT License, the full
+// text of which can be found in the LICENSE file.
+
+package wikilang
+
+import (
+	"html"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// interwiki maps link prefixes, such as "bug", to the URLs they link
+// to.  A %s in the URL is replaced by the rest of the link.
+var interwiki = map[string]string{}
+
+func init() {
+	RegisterBlockDirective("interwiki", interwikiDirective)
+}
+
+// SetInterwiki sets the prefixes that link to other sites.  With
+//
+//	"bug": "https://tracker.local/issue/%s"
+//
+// [bug:1234] links to https://tracker.local/issue/1234.  URLs without
+// a %s have the rest of the link added to the end.  Prefixes are
+// case-sensitive, so that links whose text happens to start with a
+// prefix in another case, like [Commit:Commit], are not changed, and
+// "doc" is always a doclink.
+func SetInterwiki(prefixes map[string]string) {
+	interwiki = map[string]string{}
+	for prefix, target := range prefixes {
+		if prefix != "doc" {
+			interwiki[prefix] = target
+		}
+	}
+}
+
+// interwikiUrl returns the URL of a link with an interwiki prefix,
+// such as bug:1234.
+func interwikiUrl(s string) (string, bool) {
+	parts := strings.SplitN(s, ":", 2)
+	if len(parts) != 2 {
+		return "", false
+	}
+
+	target, ok := interwiki[parts[0]]
+	if !ok {
+		return "", false
+	}
+
+	id := url.PathEscape(strings.TrimSpace(parts[1]))
+	if strings.Contains(target, "%s") {
+		return strings.Replace(target, "%s", id, -1), true
+	}
+	return target + id, true
+}
+
+// interwikiDirective renders [interwiki] as a table of the interwiki
+// prefixes and the URLs they link to.
+func interwikiDirective(args string, options Options) ParseNode {
+	if len(interwiki) == 0 {
+		return TextNode{"(No interwiki prefixes are configured.)"}
+	}
+
+	prefixes := []string{}
+	for prefix := range interwiki {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+
+	cell := func(tag string, nodes ...ParseNode) ParseNode {
+		return TagNode{tag, map[string]string{}, ParseTree{nodes}}
+	}
+	text := func(s string) ParseNode {
+		return TextNode{html.EscapeString(s)}
+	}
+
+	rows := []ParseNode{cell(TableRow, cell(TableHeading, text("Prefix")), cell(TableHeading, text("Links to")),
+		cell(TableHeading, text("Example")))}
+	for _, prefix := range prefixes {
+		rows = append(rows, cell(TableRow, cell(TableCell, text(prefix)), cell(TableCell, text(interwiki[prefix])),
+			cell(TableCell, cell(Literal, text("["+prefix+":id]")))))
+	}
+
+	return TagNode{Table, map[string]string{"class": "interwiki"}, ParseTree{rows}}
+}
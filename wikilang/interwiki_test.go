@@ -0,0 +1,45 @@
+// Copyright (c) 2014, Daniel Gallagher
+// Use of this source code is covered by the MIT License, the full
+// text of which can be found in the LICENSE file.
+
+package wikilang
+
+import (
+	"testing"
+)
+
+func TestInterwiki(t *testing.T) {
+	SetInterwiki(map[string]string{
+		"bug":    "https://tracker.local/issue/%s",
+		"RFC":    "https://www.rfc-editor.org/rfc/rfc%s.txt",
+		"commit": "https://git.local/commit/",
+		"doc":    "https://example.com/%s",
+	})
+	defer SetInterwiki(nil)
+
+	for _, data := range [...]struct {
+		data, expected string
+	}{
+		{"[bug:1234]", "<p>\n  <a href=\"https://tracker.local/issue/1234\">bug:1234</a>\n</p>\n"},
+		{"[Crash fix|bug:1234]", "<p>\n  <a href=\"https://tracker.local/issue/1234\">Crash fix</a>\n</p>\n"},
+		{"[CrashFix:bug:1234]", "<p>\n  <a href=\"https://tracker.local/issue/1234\">Crash Fix</a>\n</p>\n"},
+		{"[RFC:2616]", "<p>\n  <a href=\"https://www.rfc-editor.org/rfc/rfc2616.txt\">RFC:2616</a>\n</p>\n"},
+		{"[rfc:2616]", "<p>\n  <a href=\"2616\">rfc</a>\n</p>\n"},
+		{"[Commit:Commit]", "<p>\n  <a href=\"Commit\">Commit</a>\n</p>\n"},
+		{"[commit:a1b2 c3]", "<p>\n  <a href=\"https://git.local/commit/a1b2%20c3\">commit:a1b2 c3</a>\n</p>\n"},
+		{"[Google:http://www.google.com]", "<p>\n  <a href=\"http://www.google.com\">Google</a>\n</p>\n"},
+	} {
+		compareFlattenedParseTrees(t, PageToHtml(data.data, Options{Root: "/"}), data.expected)
+	}
+
+	compareFlattenedParseTrees(t, PageToHtml("[interwiki]", Options{}),
+		"<table class=\"interwiki\">\n  \n"+
+			"  <tr>\n    <th>Prefix</th> <th>Links to</th> <th>Example</th>\n  </tr>\n  \n"+
+			"  <tr>\n    <td>RFC</td> <td>https://www.rfc-editor.org/rfc/rfc%s.txt</td> <td><tt>[RFC:id]</tt>\n    </td>\n  </tr>\n  \n"+
+			"  <tr>\n    <td>bug</td> <td>https://tracker.local/issue/%s</td> <td><tt>[bug:id]</tt></td>\n  </tr>\n  \n"+
+			"  <tr>\n    <td>commit</td> <td>https://git.local/commit/</td><td><tt>[commit:id]</tt></td>\n  </tr>\n  \n"+
+			"</table>\n")
+
+	SetInterwiki(nil)
+	compareFlattenedParseTrees(t, PageToHtml("[interwiki]", Options{}), "<p>\n  (No interwiki prefixes are configured.)\n</p>\n")
+}
@@ -6,7 +6,9 @@ package wikilang
 
 import (
 	"bytes"
+	"strings"
 	"unicode"
+	"unicode/utf8"
 )
 
 // These are the types of tokens that the lexer may generate.
@@ -32,6 +34,8 @@ const (
 	ORDERED_LIST_ITEM_MARK   = '#' // Denote item in ordered list
 	WIKILINK_OPEN            = '[' // Begin wiki markup
 	WIKILINK_CLOSE           = ']' // End wiki markup
+	LINK_TEXT_SEPARATOR      = '|' // Separate link text from the target
+	FRAGMENT_MARK            = '#' // Separate a link's page from its section
 	LITERAL_TEXT_OPEN        = '{' // Begin literal text markup
 	LITERAL_TEXT_CLOSE       = '}' // End literal text markup
 	TAG_OPEN                 = '<' // Begin embedded HTML
@@ -56,37 +60,148 @@ type Token struct {
 type Lexer struct {
 	In  chan byte  // Channel to read bytes from
 	Out chan Token // Channel to write tokens to
+
+	pending   []byte      // Bytes that have been read ahead
+	prev, cur byte        // The last two bytes read
+	held      []heldToken // Tokens waiting for delimiters to match
+	open      []int       // Indexes of the unmatched opening delimiters in held
+	lineStart bool        // Whether the next token starts a line
+}
+
+// A heldToken is a token that the lexer has not written out yet,
+// because it may still turn out to be literal text.
+type heldToken struct {
+	Token
+	joined  bool // Whether there is no space before the token
+	literal bool // Whether the token is a delimiter used as text
 }
 
+// These are the roles a bold or emphasis mark may have, depending on
+// the bytes around it.
+const (
+	notDelimiter = iota
+	openingDelimiter
+	closingDelimiter
+)
+
 // NewLexer returns a lexer that communicates over the provided
 // channels.
 func NewLexer(i chan byte, o chan Token) Lexer {
-	return Lexer{i, o}
+	return Lexer{In: i, Out: o, lineStart: true}
 }
 
 var interrupters []byte
 
-var lexers map[byte]func(byte, chan byte) (Token, bool)
-var pending byte
-var hasPending bool
+var lexers map[byte]func(*Lexer, byte) (Token, bool)
+
+// Bytes that may come before an opening delimiter, and after a
+// closing delimiter, besides spaces and the other kind of delimiter.
+const (
+	openingContext = "'\"([{<-"
+	closingContext = "'\")]}>-.,;:!?"
+)
 
-func singleByteToken(t int) func(byte, chan byte) (Token, bool) {
-	return func(c byte, ch chan byte) (Token, bool) {
+// delimiterToken returns a lexer for bold or emphasis marks, which
+// are only delimiters at the edges of words.  Other marks, as in 10/16
+// or a*b, are text.
+func delimiterToken(t int) func(*Lexer, byte) (Token, bool) {
+	return func(l *Lexer, c byte) (Token, bool) {
+		if l.delimiter(c) == notDelimiter {
+			return defaultToken(l, c)
+		}
 		return Token{t, string(c), 0}, false
 	}
 }
 
-func defaultToken(b byte, ch chan byte) (Token, bool) {
-	value := string(b)
-	b = <-ch
-	for b != 0 && !unicode.IsSpace(rune(b)) && bytes.IndexByte(interrupters, b) < 0 {
-		value = value + string(b)
-		b = <-ch
+// isSpace returns whether a byte is an ASCII space.  Bytes that are
+// part of a multi-byte UTF-8 character are never spaces, even though
+// some of them are spaces in Latin-1.
+func isSpace(b byte) bool {
+	return b < utf8.RuneSelf && unicode.IsSpace(rune(b))
+}
+
+// read returns the next byte, either from the bytes that have been
+// read ahead or from the input channel.
+func (l *Lexer) read() byte {
+	var b byte
+	if len(l.pending) > 0 {
+		b, l.pending = l.pending[0], l.pending[1:]
+	} else {
+		b = <-l.In
+	}
+
+	l.prev, l.cur = l.cur, b
+	return b
+}
+
+// unread puts back the last byte read, so that it is read again.
+func (l *Lexer) unread(b byte) {
+	l.pending = append([]byte{b}, l.pending...)
+	l.cur = l.prev
+}
+
+// peek returns the byte after the last byte read, without reading it.
+func (l *Lexer) peek() byte {
+	return l.peekAt(0)
+}
+
+// peekAt returns the byte i bytes after the next one, without reading
+// it.  Nothing is read past the end of the input.
+func (l *Lexer) peekAt(i int) byte {
+	for len(l.pending) <= i {
+		if n := len(l.pending); n > 0 && l.pending[n-1] == 0 {
+			return 0
+		}
+		l.pending = append(l.pending, <-l.In)
+	}
+	return l.pending[i]
+}
+
+// delimiter returns the role of the bold or emphasis mark c, which
+// was the last byte read.  Like reStructuredText, a mark opens markup
+// if it starts a word, and closes markup if it ends a word:
+//
+//	*bold*, (/emphasis/), /*both*/
+//
+// Marks inside a word, or surrounded by spaces, are not delimiters.
+func (l *Lexer) delimiter(c byte) int {
+	other := byte(EMPHASIS_MARK)
+	if c == EMPHASIS_MARK {
+		other = BOLD_MARK
+	}
+
+	prev, next := l.prev, l.peek()
+	opening := (prev == 0 || isSpace(prev) || prev == other || strings.IndexByte(openingContext, prev) >= 0) &&
+		next != 0 && !isSpace(next) && next != c
+	closing := prev != 0 && !isSpace(prev) && prev != c &&
+		(next == 0 || isSpace(next) || next == other || strings.IndexByte(closingContext, next) >= 0)
+
+	switch {
+	case opening && !closing:
+		return openingDelimiter
+	case closing && !opening:
+		return closingDelimiter
+	}
+	return notDelimiter
+}
+
+func defaultToken(l *Lexer, b byte) (Token, bool) {
+	value := string([]byte{b})
+	b = l.read()
+	for b != 0 && !isSpace(b) {
+		if bytes.IndexByte(interrupters, b) >= 0 {
+			if (b != BOLD_MARK && b != EMPHASIS_MARK) || l.delimiter(b) != notDelimiter {
+				l.unread(b)
+				break
+			}
+		}
+
+		value = value + string([]byte{b})
+		b = l.read()
 	}
 
-	if bytes.IndexByte(interrupters, b) >= 0 {
-		pending = b
-		hasPending = true
+	if b == '\n' {
+		l.unread(b)
 	}
 
 	return Token{Text, value, 0}, b == 0
@@ -101,17 +216,15 @@ func init() {
 		TAG_OPEN,
 		'\n'}
 
-	lexers = make(map[byte]func(byte, chan byte) (Token, bool))
+	lexers = make(map[byte]func(*Lexer, byte) (Token, bool))
 
-	lexers[BOLD_MARK] = singleByteToken(BoldDelimeter)
-	lexers[EMPHASIS_MARK] = singleByteToken(EmphasisDelimeter)
-	lexers[UNORDERED_LIST_ITEM_MARK] = singleByteToken(UnorderedListItem)
-	lexers[ORDERED_LIST_ITEM_MARK] = singleByteToken(OrderedListItem)
+	lexers[BOLD_MARK] = delimiterToken(BoldDelimeter)
+	lexers[EMPHASIS_MARK] = delimiterToken(EmphasisDelimeter)
 
-	lexers[LITERAL_TEXT_OPEN] = func(c byte, ch chan byte) (Token, bool) {
+	lexers[LITERAL_TEXT_OPEN] = func(l *Lexer, c byte) (Token, bool) {
 		nesting := 0
 		value := ""
-		b := <-ch
+		b := l.read()
 		for (nesting > 0 || b != LITERAL_TEXT_CLOSE) && b != 0 {
 			if b == LITERAL_TEXT_OPEN {
 				nesting++
@@ -121,28 +234,28 @@ func init() {
 				nesting--
 			}
 
-			value = value + string(b)
-			b = <-ch
+			value = value + string([]byte{b})
+			b = l.read()
 		}
 
 		return Token{LiteralText, value, 0}, b == 0
 	}
-	lexers[WIKILINK_OPEN] = func(c byte, ch chan byte) (Token, bool) {
+	lexers[WIKILINK_OPEN] = func(l *Lexer, c byte) (Token, bool) {
 		value := ""
-		b := <-ch
+		b := l.read()
 		for b != WIKILINK_CLOSE && b != 0 {
-			value = value + string(b)
-			b = <-ch
+			value = value + string([]byte{b})
+			b = l.read()
 		}
 
 		return Token{WikiLink, value, 0}, b == 0
 	}
-	lexers[TAG_OPEN] = func(c byte, ch chan byte) (Token, bool) {
+	lexers[TAG_OPEN] = func(l *Lexer, c byte) (Token, bool) {
 		value := string(c)
-		b := <-ch
+		b := l.read()
 		for b != TAG_CLOSE && b != 0 {
-			value = value + string(b)
-			b = <-ch
+			value = value + string([]byte{b})
+			b = l.read()
 		}
 		if b != 0 {
 			value = value + string(TAG_CLOSE)
@@ -151,58 +264,155 @@ func init() {
 		return Token{Tag, value, 0}, b == 0
 	}
 
-	lexers['\n'] = func(b byte, ch chan byte) (Token, bool) {
+	lexers['\n'] = func(l *Lexer, b byte) (Token, bool) {
 		indent := 0
-		c := <-ch
+		c := l.read()
 		for c == ' ' {
 			indent++
-			c = <-ch
+			c = l.read()
 		}
 
 		if c != 0 {
-			pending = c
-			hasPending = true
+			l.unread(c)
 		}
 
 		return Token{NewLine, "", indent}, c == 0
 	}
-
-	pending = 0
-	hasPending = false
 }
 
 // Lex runs a Lexer.  It reads all bytes in until it reaches the end
 // mark, and writes out tokens.  When it is complete, it writes out
 // an EndOfFile token.
 //
-// Text tokens are separated by whitespace.  Bold, emphasis, and list
-// item delimeters are all single-byte tokens, only consuming the
-// single byte.  Literal text, wiki markup, and embedded HTML tags
-// consume all bytes from the open byte to the close byte.
+// Text tokens are separated by whitespace.  Bold and emphasis
+// delimeters are single-byte tokens, only consuming the single byte.
+// List item markers are only tokens at the start of a line.  Literal
+// text, wiki markup, and embedded HTML tags consume all bytes from the
+// open byte to the close byte.
+//
+// Bold and emphasis delimiters must be balanced within a line or
+// paragraph.  Delimiters that are not are written out as text.
 func (l *Lexer) Lex() {
 	eof := false
-	b := <-l.In
+	b := l.read()
 	for !eof && b != 0 {
-		if b == '\n' || !unicode.IsSpace(rune(b)) {
-			f, ok := lexers[b]
-			if ok {
-				t, end := f(b, l.In)
-				eof = end
-				l.Out <- t
-			} else {
-				t, end := defaultToken(b, l.In)
-				eof = end
-				l.Out <- t
+		if b == '\n' || !isSpace(b) {
+			joined := l.prev != 0 && !isSpace(l.prev)
+			t, ok := l.listItem(b)
+			if !ok {
+				f, ok := lexers[b]
+				if !ok {
+					f = defaultToken
+				}
+
+				t, eof = f(l, b)
 			}
+
+			l.lineStart = t.Type == NewLine
+			l.hold(heldToken{Token: t, joined: joined})
 		}
 
-		if hasPending {
-			b = pending
-			hasPending = false
-		} else if !eof {
-			b = <-l.In
+		if !eof {
+			b = l.read()
 		}
 	}
 
+	l.endScope()
+	l.flush(false)
 	l.Out <- Token{EndOfFile, "", 0}
 }
+
+// hold adds a token to the tokens waiting to be written out, matching
+// up its delimiters.  Tokens are written out once they can no longer
+// become text, except for the last one, which may still be joined to
+// the next token.
+func (l *Lexer) hold(t heldToken) {
+	if n := len(l.held); n > 0 && l.held[n-1].Type == NewLine &&
+		(t.Type == NewLine || t.Type == UnorderedListItem || t.Type == OrderedListItem) {
+		l.endScope()
+	}
+
+	if t.Type == BoldDelimeter || t.Type == EmphasisDelimeter {
+		switch l.delimiter(t.TextValue[0]) {
+		case openingDelimiter:
+			if l.isOpen(t.Type) {
+				t.literal = true
+			} else {
+				l.open = append(l.open, len(l.held))
+			}
+		case closingDelimiter:
+			t.literal = !l.close(t.Type)
+		}
+	}
+
+	l.held = append(l.held, t)
+	if len(l.open) == 0 {
+		l.flush(true)
+	}
+}
+
+// isOpen returns whether there is an unmatched opening delimiter of a
+// type.
+func (l *Lexer) isOpen(t int) bool {
+	for _, i := range l.open {
+		if l.held[i].Type == t {
+			return true
+		}
+	}
+	return false
+}
+
+// close matches a closing delimiter to the last opening delimiter of
+// its type.  Delimiters opened after that one are never closed, since
+// markup cannot overlap, so they are text.
+func (l *Lexer) close(t int) bool {
+	for n := len(l.open) - 1; n >= 0; n-- {
+		if l.held[l.open[n]].Type == t {
+			for _, i := range l.open[n+1:] {
+				l.held[i].literal = true
+			}
+			l.open = l.open[:n]
+			return true
+		}
+	}
+	return false
+}
+
+// endScope ends the line or paragraph that delimiters must be closed
+// in, so any unmatched opening delimiters are text.
+func (l *Lexer) endScope() {
+	for _, i := range l.open {
+		l.held[i].literal = true
+	}
+	l.open = nil
+}
+
+// flush writes out the held tokens, except for the last one if
+// keepLast is set.  Delimiters that are used as text become part of
+// the text next to them.
+func (l *Lexer) flush(keepLast bool) {
+	merged := []heldToken{}
+	for _, t := range l.held {
+		if t.literal {
+			t.Type = Text
+		}
+
+		last := len(merged) - 1
+		if last >= 0 && t.joined && t.Type == Text && merged[last].Type == Text &&
+			(t.literal || merged[last].literal) {
+			merged[last].TextValue += t.TextValue
+			merged[last].literal = t.literal
+			continue
+		}
+		merged = append(merged, t)
+	}
+
+	n := len(merged)
+	if keepLast && n > 0 {
+		n--
+	}
+	for _, t := range merged[:n] {
+		l.Out <- t.Token
+	}
+	l.held = merged[n:]
+}
@@ -32,10 +32,13 @@ func TestSingleToken(t *testing.T) {
 		token Token
 	}{
 		{"abcdef", Token{Text, "abcdef", 0}},
-		{"*", Token{BoldDelimeter, "*", 0}},
-		{"/", Token{EmphasisDelimeter, "/", 0}},
-		{"-", Token{UnorderedListItem, "-", 0}},
-		{"#", Token{OrderedListItem, "#", 0}},
+		{"*", Token{Text, "*", 0}},
+		{"/", Token{Text, "/", 0}},
+		{"- ", Token{UnorderedListItem, "-", 0}},
+		{"# ", Token{OrderedListItem, "#", 0}},
+		{"(iii) ", Token{OrderedListItem, "(iii)", 0}},
+		{"-", Token{Text, "-", 0}},
+		{"#project", Token{Text, "#project", 0}},
 		{"{Some stuff that may *contain* /other/ tokens}",
 			Token{LiteralText, "Some stuff that may *contain* /other/ tokens", 0}},
 		{"{Multi-level {Literal Text}",
@@ -54,7 +57,7 @@ func TestSingleToken(t *testing.T) {
 		out := make(chan Token)
 		done := make(chan int)
 
-		lexer := &Lexer{in, out}
+		lexer := NewLexer(in, out)
 
 		reader := strings.NewReader(data.data)
 		go func() {
@@ -134,6 +137,27 @@ func TestMultiToken(t *testing.T) {
 			Token{Text, "A", 0},
 			Token{Text, "more", 0},
 			Token{WikiLink, "ComplexLink:http://othersite.com", 0}}},
+		{"a - b, issue #12\n12. Twelve\nb) Bee", &[]Token{
+			Token{Text, "a", 0},
+			Token{Text, "-", 0},
+			Token{Text, "b,", 0},
+			Token{Text, "issue", 0},
+			Token{Text, "#12", 0},
+			Token{NewLine, "", 0},
+			Token{OrderedListItem, "12.", 0},
+			Token{Text, "Twelve", 0},
+			Token{NewLine, "", 0},
+			Token{OrderedListItem, "b)", 0},
+			Token{Text, "Bee", 0}}},
+		{"Path src/foo/bar.c and *a*b", &[]Token{
+			Token{Text, "Path", 0},
+			Token{Text, "src/foo/bar.c", 0},
+			Token{Text, "and", 0},
+			Token{Text, "*a*b", 0}}},
+		{"Unclosed /emphasis here", &[]Token{
+			Token{Text, "Unclosed", 0},
+			Token{Text, "/emphasis", 0},
+			Token{Text, "here", 0}}},
 		{"Lots of    \tspaces: <a href=\"http://othersite.com\">", &[]Token{
 			Token{Text, "Lots", 0},
 			Token{Text, "of", 0},
@@ -143,7 +167,7 @@ func TestMultiToken(t *testing.T) {
 		in := make(chan byte)
 		out := make(chan Token)
 
-		lexer := &Lexer{in, out}
+		lexer := NewLexer(in, out)
 
 		reader := strings.NewReader(data.data)
 		go func() {
@@ -0,0 +1,155 @@
+// Copyright (c) 2014, Daniel Gallagher
+// Use of this source code is covered by the MIT License, the full
+// text of which can be found in the LICENSE file.
+
+package wikilang
+
+import (
+	"bytes"
+	"strings"
+)
+
+// Links returns the targets of all of the wikilinks in a body of wiki
+// text, in the order they appear.  Links are resolved with the page's
+// options the same way they are when the page is converted to HTML,
+// so [doc::entity] becomes [doc:project:entity] on a page with a
+// default project.
+func Links(body string, options Options) []string {
+	parser := NewParser(nil, nil)
+	parser.Options = options
+
+	links := []string{}
+	for _, token := range lex(body) {
+		if token.Type == WikiLink {
+			_, target := splitLinkText(parser.resolveWikiLink(token).TextValue)
+			links = append(links, target)
+		}
+	}
+
+	return links
+}
+
+// parse returns the parse trees of a body of wiki text, one for each
+// top-level paragraph.
+func parse(body string, options Options) []ParseTree {
+	tokens := make(chan Token)
+	trees := make(chan ParseTree)
+
+	parser := NewParser(tokens, trees)
+	parser.Options = options
+	go func() {
+		for _, token := range lex(body) {
+			tokens <- token
+		}
+		tokens <- Token{EndOfFile, "", 0}
+	}()
+	go parser.Parse()
+
+	result := []ParseTree{}
+	for tree := <-trees; len(tree.Nodes) > 0; tree = <-trees {
+		result = append(result, tree)
+	}
+
+	return result
+}
+
+// lex returns all of the tokens in a body of wiki text, not including
+// the EndOfFile token.
+func lex(body string) []Token {
+	data := make(chan byte)
+	tokens := make(chan Token)
+
+	lexer := NewLexer(data, tokens)
+	go func() {
+		for _, c := range []byte(body) {
+			data <- c
+		}
+		data <- 0
+	}()
+	go lexer.Lex()
+
+	result := []Token{}
+	for token := range tokens {
+		if token.Type == EndOfFile {
+			break
+		}
+		result = append(result, token)
+	}
+
+	return result
+}
+
+// A linkSpan is the target of a wikilink and where it is in the wiki
+// text, not including the brackets or the link's own text.
+type linkSpan struct {
+	target     string
+	start, end int
+}
+
+// linkSpans finds the wikilinks in a body of wiki text.  The lexer
+// does not keep track of where its tokens are, so each token is found
+// by searching the text after the previous one.  This keeps link-like
+// text inside of literal text and HTML tags from being mistaken for a
+// link.
+func linkSpans(body string) []linkSpan {
+	spans := []linkSpan{}
+
+	cursor := 0
+	for _, token := range lex(body) {
+		raw := token.TextValue
+		switch token.Type {
+		case WikiLink:
+			raw = string(WIKILINK_OPEN) + raw
+		case LiteralText:
+			raw = string(LITERAL_TEXT_OPEN) + raw
+		case NewLine:
+			raw = "\n"
+		}
+
+		i := strings.Index(body[cursor:], raw)
+		if i < 0 {
+			break
+		}
+		start := cursor + i
+		cursor = start + len(raw)
+
+		if token.Type == WikiLink {
+			_, target := splitLinkText(token.TextValue)
+			spans = append(spans, linkSpan{target, start + 1 + targetStart(token.TextValue), cursor})
+		}
+	}
+
+	return spans
+}
+
+// RenameLinks rewrites every wikilink to the page oldTitle in a body
+// of wiki text to link to newTitle instead.  Relative links are
+// resolved with the page's options, and are replaced by the full new
+// title.  Links are compared by their canonical titles, without
+// regard to case, the same way pages are looked up.  Links to a
+// section of the page keep the section.  Everything else in the text
+// is left as it was.  It returns the new text and the number of links
+// that were changed.
+func RenameLinks(body, oldTitle, newTitle string, options Options) (string, int) {
+	var buf bytes.Buffer
+
+	count := 0
+	last := 0
+	for _, span := range linkSpans(body) {
+		title, section := SplitFragment(span.target)
+		if !strings.EqualFold(CanonicalTitle(ResolveTitle(options.Page, title)), oldTitle) {
+			continue
+		}
+
+		buf.WriteString(body[last:span.start])
+		buf.WriteString(newTitle)
+		last = span.end
+		if section != "" {
+			last = span.start + len(title)
+		}
+		count++
+	}
+	buf.WriteString(body[last:])
+
+	return buf.String(), count
+}
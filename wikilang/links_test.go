@@ -0,0 +1,64 @@
+// Copyright (c) 2014, Daniel Gallagher
+// Use of this source code is covered by the MIT License, the full
+// text of which can be found in the LICENSE file.
+
+package wikilang
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLinks(t *testing.T) {
+	for _, data := range [...]struct {
+		data     string
+		options  Options
+		expected string
+	}{
+		{"No links", Options{}, ""},
+		{"A [WikiLink] and [Google:http://www.google.com]", Options{},
+			"WikiLink,Google:http://www.google.com"},
+		{"{[NotALink]} but *[BoldLink]*", Options{}, "BoldLink"},
+		{"[doc::Widget] and [doc:other:Gadget]", Options{Project: "big"},
+			"doc:big:Widget,doc:other:Gadget"},
+		{"[the widget|doc::Widget] and [the design|../Design]", Options{Project: "big", Page: "ProjA/Threading"},
+			"doc:big:Widget,ProjA/Design"},
+	} {
+		compareFlattenedParseTrees(t, strings.Join(Links(data.data, data.options), ","), data.expected)
+	}
+}
+
+func TestRenameLinks(t *testing.T) {
+	for _, data := range [...]struct {
+		data     string
+		count    int
+		expected string
+	}{
+		{"See [OldPage].", 1, "See [NewPage]."},
+		{"[OldPage] and [OldPage]\r\n    - [OldPage]", 3, "[NewPage] and [NewPage]\r\n    - [NewPage]"},
+		{"Not [OldPageToo] or [Old:OldPage] or [doc:OldPage:x]", 0, "Not [OldPageToo] or [Old:OldPage] or [doc:OldPage:x]"},
+		{"Literal {[OldPage]} but [OldPage]", 1, "Literal {[OldPage]} but [NewPage]"},
+		{"<span title=\"[OldPage]\">[OldPage]</span>", 1, "<span title=\"[OldPage]\">[NewPage]</span>"},
+		{"/*Heading [OldPage]*/\n\n{\nliteral\n}\n[OldPage]", 2, "/*Heading [NewPage]*/\n\n{\nliteral\n}\n[NewPage]"},
+		{"Unterminated [OldPage", 1, "Unterminated [NewPage"},
+		{"[Old Page], [old page] and [OLDPAGE]", 3, "[NewPage], [NewPage] and [NewPage]"},
+		{"See [the old page|OldPage] and [OldPage|Other]", 1, "See [the old page|NewPage] and [OldPage|Other]"},
+		{"[old|\n  OldPage]", 1, "[old|\n  NewPage]"},
+		{"[OldPage#Lock Order] and [#Lock Order]", 1, "[NewPage#Lock Order] and [#Lock Order]"},
+	} {
+		actual, count := RenameLinks(data.data, "OldPage", "NewPage", Options{})
+		compareFlattenedParseTrees(t, actual, data.expected)
+		if count != data.count {
+			t.Errorf("Expected %d links renamed in %q, got %d", data.count, data.data, count)
+		}
+	}
+}
+
+func TestRenameRelativeLinks(t *testing.T) {
+	actual, count := RenameLinks("[../OldPage] [./OldPage] [ProjA/OldPage] [OldPage]", "ProjA/OldPage", "ProjB/NewPage",
+		Options{Page: "ProjA/Design"})
+	compareFlattenedParseTrees(t, actual, "[ProjB/NewPage] [./OldPage] [ProjB/NewPage] [OldPage]")
+	if count != 2 {
+		t.Errorf("Expected 2 links renamed, got %d", count)
+	}
+}
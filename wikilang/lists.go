@@ -0,0 +1,222 @@
+// Copyright (c) 2014, Daniel Gallagher
+// Use of this source code is covered by the MIT License, the full
+// text of which can be found in the LICENSE file.
+
+package wikilang
+
+import (
+	"strconv"
+	"strings"
+)
+
+// maxListMarker is the longest list item marker, so that the lexer
+// does not read far ahead on every line.
+const maxListMarker = 12
+
+// listItem reads the list item marker that b starts, if b is the first
+// byte of a line.  Markers are followed by a space, and are either a
+// dash for a bulleted list, or an enumerator for a numbered list, as
+// in reStructuredText:
+//
+//	# item, #. item, 1. item, a) item, (iii) item
+//
+// Anything else, like #project or -1, is not a list item.
+func (l *Lexer) listItem(b byte) (Token, bool) {
+	if !l.lineStart {
+		return Token{}, false
+	}
+
+	marker := string([]byte{b})
+	for i := 0; ; i++ {
+		next := l.peekAt(i)
+		if next == ' ' || next == '\t' {
+			break
+		}
+		if next == 0 || isSpace(next) || len(marker) >= maxListMarker {
+			return Token{}, false
+		}
+		marker = marker + string([]byte{next})
+	}
+
+	t := Token{OrderedListItem, marker, 0}
+	if marker == string(UNORDERED_LIST_ITEM_MARK) {
+		t.Type = UnorderedListItem
+	} else if _, _, ok := parseEnumerator(marker); !ok {
+		return Token{}, false
+	}
+
+	for i := 1; i < len(marker); i++ {
+		l.read()
+	}
+	return t, true
+}
+
+// parseEnumerator returns the number of an ordered list item marker,
+// and the style of numbering it uses, which is the type of an <ol>:
+// "1", "a", "A", "i", or "I".  Auto-numbered markers, like # and #.,
+// are numbered 1.
+func parseEnumerator(marker string) (int, string, bool) {
+	if marker == string(ORDERED_LIST_ITEM_MARK) {
+		return 1, "1", true
+	}
+
+	switch {
+	case len(marker) > 2 && marker[0] == '(' && marker[len(marker)-1] == ')':
+		marker = marker[1 : len(marker)-1]
+	case len(marker) > 1 && (marker[len(marker)-1] == '.' || marker[len(marker)-1] == ')'):
+		marker = marker[:len(marker)-1]
+	default:
+		return 0, "", false
+	}
+
+	if marker == string(ORDERED_LIST_ITEM_MARK) {
+		return 1, "1", true
+	}
+
+	if strings.Trim(marker, "0123456789") == "" {
+		if n, err := strconv.Atoi(marker); err == nil {
+			return n, "1", true
+		}
+	}
+
+	if n := romanValue(marker); n > 0 && (len(marker) > 1 || marker == "i" || marker == "I") {
+		if marker == strings.ToUpper(marker) {
+			return n, "I", true
+		}
+		return n, "i", true
+	}
+
+	if len(marker) == 1 && 'a' <= marker[0] && marker[0] <= 'z' {
+		return int(marker[0]-'a') + 1, "a", true
+	}
+	if len(marker) == 1 && 'A' <= marker[0] && marker[0] <= 'Z' {
+		return int(marker[0]-'A') + 1, "A", true
+	}
+
+	return 0, "", false
+}
+
+// proseItems turns back into text the ordered list items of a
+// paragraph whose markers are often just prose at the start of a line,
+// like "a) see below" or "(c) 2014".  Lettered, roman and parenthesised
+// markers only make list items next to another item of the same list,
+// at the same indentation.  The tokens must have been indented.
+func proseItems(tokens []Token) []Token {
+	prose := []int{}
+	for i, t := range tokens {
+		if t.Type != OrderedListItem || !proseMarker(t.TextValue) {
+			continue
+		}
+
+		prev, next := neighbourItem(tokens, i, -1), neighbourItem(tokens, i, 1)
+		if (prev < 0 || !sameList(tokens[prev].TextValue, t.TextValue)) &&
+			(next < 0 || !sameList(t.TextValue, tokens[next].TextValue)) {
+			prose = append(prose, i)
+		}
+	}
+
+	for _, i := range prose {
+		tokens[i].Type = Text
+	}
+	return tokens
+}
+
+// proseMarker returns whether an ordered list item marker could as
+// well start a sentence.
+func proseMarker(marker string) bool {
+	_, style, ok := parseEnumerator(marker)
+	return ok && (style != "1" || marker[0] == '(')
+}
+
+// neighbourItem returns the index of the list item before (step -1)
+// or after (step 1) tokens[i] at the same indentation, or -1 if the
+// list ends first.
+func neighbourItem(tokens []Token, i, step int) int {
+	for j := i + step; j >= 0 && j < len(tokens); j += step {
+		switch {
+		case tokens[j].IntValue < tokens[i].IntValue:
+			return -1
+		case tokens[j].IntValue == tokens[i].IntValue &&
+			(tokens[j].Type == OrderedListItem || tokens[j].Type == UnorderedListItem):
+			return j
+		}
+	}
+	return -1
+}
+
+// sameList returns whether ordered list item markers a and then b
+// number consecutive items of one list.
+func sameList(a, b string) bool {
+	an, aStyle, aOk := parseEnumerator(a)
+	bn, bStyle, bOk := parseEnumerator(b)
+	if !aOk || !bOk || aStyle != bStyle ||
+		(a[0] == '(') != (b[0] == '(') || a[len(a)-1] != b[len(b)-1] {
+		return false
+	}
+	auto := string(ORDERED_LIST_ITEM_MARK)
+	return bn == an+1 || strings.Contains(a, auto) || strings.Contains(b, auto)
+}
+
+var romanNumerals = []struct {
+	value   int
+	numeral string
+}{
+	{1000, "m"}, {900, "cm"}, {500, "d"}, {400, "cd"},
+	{100, "c"}, {90, "xc"}, {50, "l"}, {40, "xl"},
+	{10, "x"}, {9, "ix"}, {5, "v"}, {4, "iv"}, {1, "i"},
+}
+
+// romanValue returns the value of a roman numeral, in all upper or all
+// lower case, or 0 if s is not a roman numeral written the usual way.
+func romanValue(s string) int {
+	lower := strings.ToLower(s)
+	if s != lower && s != strings.ToUpper(s) {
+		return 0
+	}
+
+	value := 0
+	rest := lower
+	for _, r := range romanNumerals {
+		for strings.HasPrefix(rest, r.numeral) {
+			value += r.value
+			rest = rest[len(r.numeral):]
+		}
+	}
+
+	if rest != "" || lower == "" || roman(value) != lower {
+		return 0
+	}
+	return value
+}
+
+// roman returns the lower case roman numeral for n.
+func roman(n int) string {
+	s := ""
+	for _, r := range romanNumerals {
+		for n >= r.value {
+			s += r.numeral
+			n -= r.value
+		}
+	}
+	return s
+}
+
+// listAttributes returns the attributes of the list that a list item
+// starts.  Ordered lists that do not count 1, 2, 3, ... from 1 have
+// start and type attributes.
+func listAttributes(t Token) map[string]string {
+	attributes := map[string]string{}
+	if t.Type != OrderedListItem {
+		return attributes
+	}
+
+	if n, style, ok := parseEnumerator(t.TextValue); ok {
+		if n != 1 {
+			attributes["start"] = strconv.Itoa(n)
+		}
+		if style != "1" {
+			attributes["type"] = style
+		}
+	}
+	return attributes
+}
@@ -0,0 +1,261 @@
+// Copyright (c) 2014, Daniel Gallagher
+// Use of this source code is covered by the MIT License, the full
+// text of which can be found in the LICENSE file.
+
+package wikilang
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A Member is a public member of a class (or of any other Doxygen
+// compound), as described by Doxygen's XML output.
+type Member struct {
+	Kind      string // Doxygen kind of the member, e.g., function
+	Name      string // Unqualified name of the member
+	Signature string // Declaration of the member, e.g., "int size() const"
+	Brief     string // Brief description of the member
+	Url       string // Link to the member's documentation
+}
+
+func init() {
+	RegisterBlockDirective("docmembers", docMembersDirective)
+}
+
+// xmlText is the text of an XML element with any markup, such as
+// Doxygen's <ref> elements, removed and whitespace collapsed.
+type xmlText string
+
+func (t *xmlText) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var buf bytes.Buffer
+	for depth := 0; depth >= 0; {
+		token, err := d.Token()
+		if err != nil {
+			return err
+		}
+
+		switch token := token.(type) {
+		case xml.StartElement:
+			depth++
+		case xml.EndElement:
+			depth--
+		case xml.CharData:
+			buf.Write(token)
+		}
+	}
+
+	*t = xmlText(strings.Join(strings.Fields(buf.String()), " "))
+	return nil
+}
+
+// A doxygenLocation is where a compound or member is declared and,
+// if it has a body, where the body is.  Line numbers start at 1.
+type doxygenLocation struct {
+	File      string `xml:"file,attr"`
+	Line      int    `xml:"line,attr"`
+	BodyFile  string `xml:"bodyfile,attr"`
+	BodyStart int    `xml:"bodystart,attr"`
+	BodyEnd   int    `xml:"bodyend,attr"`
+}
+
+type doxygenMember struct {
+	Kind     string          `xml:"kind,attr"`
+	Id       string          `xml:"id,attr"`
+	Prot     string          `xml:"prot,attr"`
+	Static   string          `xml:"static,attr"`
+	Type     xmlText         `xml:"type"`
+	Name     string          `xml:"name"`
+	Args     string          `xml:"argsstring"`
+	Brief    xmlText         `xml:"briefdescription"`
+	Location doxygenLocation `xml:"location"`
+}
+
+// A doxygenCompound is a class, namespace, file, etc., from the
+// Doxygen XML.
+type doxygenCompound struct {
+	Location doxygenLocation `xml:"compounddef>location"`
+	Sections []struct {
+		Members []doxygenMember `xml:"memberdef"`
+	} `xml:"compounddef>sectiondef"`
+}
+
+// members returns all of the compound's members, in the order that
+// Doxygen lists them.
+func (c doxygenCompound) members() []doxygenMember {
+	members := []doxygenMember{}
+	for _, section := range c.Sections {
+		members = append(members, section.Members...)
+	}
+	return members
+}
+
+// signature builds a member's declaration from its parts.
+func (m doxygenMember) signature() string {
+	parts := []string{}
+	if m.Static == "yes" {
+		parts = append(parts, "static")
+	}
+	if m.Kind == "enum" || m.Kind == "typedef" {
+		parts = append(parts, m.Kind)
+	}
+	if m.Type != "" {
+		parts = append(parts, string(m.Type))
+	}
+	return strings.Join(append(parts, m.Name+m.Args), " ")
+}
+
+// A compoundIndex maps the names of the compounds in a project's
+// Doxygen XML to the files that describe them.  It is reread when
+// Doxygen's index.xml changes.
+type compoundIndex struct {
+	modTime time.Time
+	refids  map[string]string
+}
+
+var compoundMutex sync.Mutex
+var compoundIndexes = map[string]compoundIndex{}
+
+// compoundRefid finds the Doxygen id of a compound, which names the
+// XML file describing it and the HTML page documenting it.
+func compoundRefid(xmlRoot, name string) (string, error) {
+	file := filepath.Join(xmlRoot, "index.xml")
+	info, err := os.Stat(file)
+	if err != nil {
+		return "", err
+	}
+
+	compoundMutex.Lock()
+	defer compoundMutex.Unlock()
+
+	index, ok := compoundIndexes[xmlRoot]
+	if !ok || !index.modTime.Equal(info.ModTime()) {
+		var result struct {
+			Compounds []struct {
+				Refid string `xml:"refid,attr"`
+				Name  string `xml:"name"`
+			} `xml:"compound"`
+		}
+
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return "", err
+		}
+		if err = xml.Unmarshal(data, &result); err != nil {
+			return "", fmt.Errorf("Could not read %s: %s", file, err)
+		}
+
+		index = compoundIndex{info.ModTime(), map[string]string{}}
+		for _, compound := range result.Compounds {
+			index.refids[compound.Name] = compound.Refid
+		}
+		compoundIndexes[xmlRoot] = index
+	}
+
+	refid, ok := index.refids[name]
+	if !ok {
+		return "", fmt.Errorf("%s is not in %s", name, file)
+	}
+	return refid, nil
+}
+
+// readCompound reads the Doxygen XML for a compound.
+func readCompound(xmlRoot, name string) (string, doxygenCompound, error) {
+	var compound doxygenCompound
+
+	refid, err := compoundRefid(xmlRoot, name)
+	if err != nil {
+		return "", compound, err
+	}
+
+	file := filepath.Join(xmlRoot, refid+".xml")
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", compound, err
+	}
+	if err = xml.Unmarshal(data, &compound); err != nil {
+		return "", compound, fmt.Errorf("Could not read %s: %s", file, err)
+	}
+
+	return refid, compound, nil
+}
+
+// ClassMembers returns the public members of a class in a project
+// specification, in the order that Doxygen lists them.
+func ClassMembers(spec, class string) ([]Member, error) {
+	project, version, indexer, ok := lookupIndex(spec)
+	if !ok {
+		return nil, fmt.Errorf("unknown project %s", spec)
+	}
+
+	refid, compound, err := readCompound(indexer.xmlRoot, class)
+	if err != nil {
+		return nil, err
+	}
+
+	members := []Member{}
+	for _, m := range compound.members() {
+		if m.Prot != "public" {
+			continue
+		}
+
+		// Member ids are the compound's id, "_1", and the anchor of
+		// the member on the compound's page.
+		page := refid + ".html"
+		if strings.HasPrefix(m.Id, refid+"_1") {
+			page += "#" + m.Id[len(refid)+2:]
+		}
+
+		members = append(members, Member{m.Kind, m.Name, m.signature(), string(m.Brief),
+			docUrl(DocUrlName(project.name, version), page)})
+	}
+	return members, nil
+}
+
+// docMembersDirective renders [docmembers:project:Class] as a table
+// of the class's public members.  As with doclinks, the project may
+// be left empty to use the page's default project.
+func docMembersDirective(args string, options Options) ParseNode {
+	parts := strings.SplitN(args, ":", 2)
+	if len(parts) != 2 {
+		return TextNode{"(docmembers needs a project and a class)"}
+	}
+
+	spec, class := parts[0], parts[1]
+	if spec == "" {
+		spec = options.Project
+	}
+
+	members, err := ClassMembers(spec, class)
+	if err != nil {
+		return TextNode{html.EscapeString("(" + err.Error() + ")")}
+	}
+	if len(members) == 0 {
+		return TextNode{html.EscapeString(class + " has no public members.")}
+	}
+
+	cell := func(tag string, nodes ...ParseNode) ParseNode {
+		return TagNode{tag, map[string]string{}, ParseTree{nodes}}
+	}
+	text := func(s string) ParseNode {
+		return TextNode{html.EscapeString(s)}
+	}
+
+	rows := []ParseNode{cell(TableRow, cell(TableHeading, text("Kind")), cell(TableHeading, text("Member")),
+		cell(TableHeading, text("Signature")), cell(TableHeading, text("Description")))}
+	for _, m := range members {
+		link := TagNode{Link, map[string]string{"href": m.Url, "title": spec}, ParseTree{[]ParseNode{text(m.Name)}}}
+		rows = append(rows, cell(TableRow, cell(TableCell, text(m.Kind)), cell(TableCell, link),
+			cell(TableCell, cell(Literal, text(m.Signature))), cell(TableCell, text(m.Brief))))
+	}
+
+	return TagNode{Table, map[string]string{"class": "docmembers"}, ParseTree{rows}}
+}
@@ -0,0 +1,98 @@
+// Copyright (c) 2014, Daniel Gallagher
+// Use of this source code is covered by the MIT License, the full
+// text of which can be found in the LICENSE file.
+
+package wikilang
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testDoxygenIndex = `<?xml version='1.0' encoding='UTF-8' standalone='no'?>
+<doxygenindex version="1.8.6">
+  <compound refid="classgui_1_1Window" kind="class"><name>gui::Window</name>
+    <member refid="classgui_1_1Window_1a1" kind="function"><name>draw</name></member>
+  </compound>
+</doxygenindex>
+`
+
+const testDoxygenClass = `<?xml version='1.0' encoding='UTF-8' standalone='no'?>
+<doxygen version="1.8.6">
+  <compounddef id="classgui_1_1Window" kind="class" prot="public">
+    <compoundname>gui::Window</compoundname>
+    <sectiondef kind="public-func">
+      <memberdef kind="function" id="classgui_1_1Window_1a1" prot="public" static="no" const="no">
+        <type>void</type>
+        <definition>void gui::Window::draw</definition>
+        <argsstring>(const std::vector&lt; Widget &gt; &amp;widgets)</argsstring>
+        <name>draw</name>
+        <briefdescription><para>Draws the <ref refid="classWidget">Widget</ref>s. </para></briefdescription>
+      </memberdef>
+      <memberdef kind="function" id="classgui_1_1Window_1a2" prot="public" static="yes" const="no">
+        <type><ref refid="classgui_1_1Window">Window</ref> *</type>
+        <argsstring>()</argsstring>
+        <name>current</name>
+        <briefdescription></briefdescription>
+      </memberdef>
+    </sectiondef>
+    <sectiondef kind="private-attrib">
+      <memberdef kind="variable" id="classgui_1_1Window_1a3" prot="private" static="no">
+        <type>int</type>
+        <argsstring></argsstring>
+        <name>width_</name>
+      </memberdef>
+    </sectiondef>
+  </compounddef>
+</doxygen>
+`
+
+func TestDocMembers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "members")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ioutil.WriteFile(filepath.Join(dir, "index.xml"), []byte(testDoxygenIndex), 0600)
+	ioutil.WriteFile(filepath.Join(dir, "classgui_1_1Window.xml"), []byte(testDoxygenClass), 0600)
+
+	addTestProject("members", map[string]entity{})
+	projectDocs["members"].indexes[""].xmlRoot = dir
+	defer delete(projectDocs, "members")
+
+	compareFlattenedParseTrees(t, PageToHtml("Before.\n\n[docmembers::gui::Window]\n\nAfter.", Options{Project: "members"}),
+		"<p>\n"+
+			"  Before.\n"+
+			"</p>\n"+
+			"\n\n"+
+			"<table class=\"docmembers\">\n"+
+			"  \n"+
+			"  <tr>\n"+
+			"    <th>Kind</th> <th>Member</th> <th>Signature</th> <th>Description</th>\n"+
+			"  </tr>\n"+
+			"  \n"+
+			"  <tr>\n"+
+			"    <td>function</td> <td><a href=\"../doc/members/html/classgui_1_1Window.html#a1\" title=\"members\"\n"+
+			"    >draw</a></td> <td><tt>void draw(const std::vector&lt; Widget &gt; &amp;widgets)\n"+
+			"    </tt></td><td>Draws the Widgets.</td>\n"+
+			"  </tr>\n"+
+			"  \n"+
+			"  <tr>\n"+
+			"    <td>function</td> <td><a href=\"../doc/members/html/classgui_1_1Window.html#a2\" title=\"members\"\n"+
+			"    >current</a></td> <td><tt>static Window * current()</tt></td><td></td>\n"+
+			"  </tr>\n"+
+			"  \n"+
+			"</table>\n"+
+			"\n\n"+
+			"<p>\n"+
+			"  After.\n"+
+			"</p>\n")
+
+	compareFlattenedParseTrees(t, WikiToHtml("[docmembers:members:Missing]"),
+		"<p>\n  (Missing is not in "+filepath.Join(dir, "index.xml")+")\n</p>\n")
+	compareFlattenedParseTrees(t, WikiToHtml("[docmembers:nothing:Widget]"),
+		"<p>\n  (unknown project nothing)\n</p>\n")
+}
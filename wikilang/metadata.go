@@ -0,0 +1,56 @@
+// Copyright (c) 2014, Daniel Gallagher
+// Use of this source code is covered by the MIT License, the full
+// text of which can be found in the LICENSE file.
+
+package wikilang
+
+import (
+	"regexp"
+	"strings"
+)
+
+var metadataLine = regexp.MustCompile(`^#([a-z]+)(?:[ \t]+(.*))?$`)
+
+// metadataNames are the names of the metadata that the wiki uses.
+// Lines starting with any other #name are part of the body.
+var metadataNames = map[string]bool{
+	"alias":    true,
+	"autolink": true,
+	"entity":   true,
+	"project":  true,
+	"redirect": true,
+	"title":    true,
+}
+
+// ParseMetadata splits the metadata lines at the top of a page from
+// the page's body.  Metadata lines have the form
+//
+//	#name value
+//
+// where the name is one of the metadataNames and immediately follows
+// the hash mark.  This keeps them distinct from ordered list items,
+// which have a space after the hash mark, and from text that happens
+// to start with a hash mark.  Parsing stops at the first line that is
+// not a metadata line.
+func ParseMetadata(body string) (map[string]string, string) {
+	meta := map[string]string{}
+
+	for len(body) > 0 {
+		line := body
+		rest := ""
+		if i := strings.IndexByte(body, '\n'); i >= 0 {
+			line = body[:i]
+			rest = body[i+1:]
+		}
+
+		match := metadataLine.FindStringSubmatch(strings.TrimRight(line, "\r"))
+		if match == nil || !metadataNames[match[1]] {
+			break
+		}
+
+		meta[match[1]] = strings.TrimSpace(match[2])
+		body = rest
+	}
+
+	return meta, body
+}
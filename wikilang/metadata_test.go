@@ -0,0 +1,29 @@
+// Copyright (c) 2014, Daniel Gallagher
+// Use of this source code is covered by the MIT License, the full
+// text of which can be found in the LICENSE file.
+
+package wikilang
+
+import (
+	"testing"
+)
+
+func TestParseMetadata(t *testing.T) {
+	for _, data := range [...]struct {
+		data, meta, body string
+	}{
+		{"No metadata", "", "No metadata"},
+		{"#project big\nBody", "project=big ", "Body"},
+		{"#project big\r\n#title Some Title\r\nBody\r\n", "project=big title=Some Title ", "Body\r\n"},
+		{"#autolink\nBody", "autolink= ", "Body"},
+		{"#first step\nBody", "", "#first step\nBody"},
+		{"#project big\n#unknown name\nBody", "project=big ", "#unknown name\nBody"},
+		{"# Ordered list item\n#project big", "", "# Ordered list item\n#project big"},
+		{"Body\n#project big", "", "Body\n#project big"},
+		{"#project big", "project=big ", ""},
+	} {
+		meta, body := ParseMetadata(data.data)
+		compareFlattenedParseTrees(t, TagNode{"", meta, ParseTree{}}.String(), "{Tag  ("+data.meta+") []}")
+		compareFlattenedParseTrees(t, body, data.body)
+	}
+}
@@ -6,7 +6,11 @@ package wikilang
 
 import (
 	"fmt"
+	"html"
 	"net/url"
+	"path"
+	"regexp"
+	"sort"
 	"strings"
 )
 
@@ -14,17 +18,80 @@ import (
 // happen to correspond directly to the HTML tag used by the HTML
 // generator, but that is only a convenience.
 const (
-	Paragraph     = "p"   // Container tag for a paragraph
-	OrderedList   = "ol"  // Container tag for an ordered list
-	UnorderedList = "ul"  // Container tag for an unordered list
-	ListItem      = "li"  // An item in a list
-	Link          = "a"   // A link
-	Bold          = "b"   // Bold text
-	Emphasis      = "em"  // Emphasized text
-	Literal       = "tt"  // Literal text embedded in a single line
-	Preformatted  = "pre" // Multi-line literal text
+	Paragraph     = "p"     // Container tag for a paragraph
+	OrderedList   = "ol"    // Container tag for an ordered list
+	UnorderedList = "ul"    // Container tag for an unordered list
+	ListItem      = "li"    // An item in a list
+	Link          = "a"     // A link
+	Bold          = "b"     // Bold text
+	Emphasis      = "em"    // Emphasized text
+	Literal       = "tt"    // Literal text embedded in a single line
+	Preformatted  = "pre"   // Multi-line literal text
+	Table         = "table" // A table
+	TableRow      = "tr"    // A row in a table
+	TableHeading  = "th"    // A heading cell in a table row
+	TableCell     = "td"    // A cell in a table row
+	Division      = "div"   // A group of other tags
 )
 
+// relativeRoot is the path from a page to the root of the wiki.  It
+// starts the URLs of links to other pages and to Doxygen, unless the
+// page's options give a different root.
+const relativeRoot = "../"
+
+// directiveTag marks a placeholder node for a directive in a parse
+// tree.  The parser replaces it with the directive's output before
+// the tree is written out.
+const directiveTag = "directive"
+
+// A Directive generates the contents of a wikilink that is not really
+// a link, such as [docchanges:project].  It is given the text after
+// the directive's name and the options of the page it is on.
+type Directive func(args string, options Options) ParseNode
+
+var directives = map[string]Directive{}
+var blockDirectives = map[string]bool{}
+
+// RegisterDirective makes [name:args] (or just [name]) call the
+// directive instead of linking to another page.
+func RegisterDirective(name string, directive Directive) {
+	directives[name] = directive
+}
+
+// RegisterBlockDirective registers a directive whose output, such as
+// a table, replaces the paragraph it is in when it is the only thing
+// in that paragraph.
+func RegisterBlockDirective(name string, directive Directive) {
+	RegisterDirective(name, directive)
+	blockDirectives[name] = true
+}
+
+// blockDirective returns the directive placeholder that makes up all
+// of a paragraph, if the directive is a block directive.
+func blockDirective(n TagNode) (TagNode, bool) {
+	if n.Tag != Paragraph || len(n.Tree.Nodes) != 1 {
+		return TagNode{}, false
+	}
+
+	child, ok := n.Tree.Nodes[0].(TagNode)
+	if !ok || child.Tag != directiveTag || !blockDirectives[child.Attributes["name"]] {
+		return TagNode{}, false
+	}
+	return child, true
+}
+
+// splitDirective returns the directive named by a wikilink, if any.
+func splitDirective(s string) (name, args string, ok bool) {
+	parts := strings.SplitN(s, ":", 2)
+	if _, ok = directives[parts[0]]; !ok {
+		return "", "", false
+	}
+	if len(parts) == 2 {
+		args = parts[1]
+	}
+	return parts[0], args, true
+}
+
 // A Visitor allows the parse tree to call a function for each node.
 // Parse tree implements the visitor pattern, and the visitor must
 // implement this interface.  The specific type of node is already
@@ -79,16 +146,18 @@ type TextNode struct {
 // communicate with the lexer and the generator.  It reads tokens in,
 // and emits full parse trees, one per top-level paragraph.
 type Parser struct {
-	In  chan Token     // Channel to read tokens from
-	Out chan ParseTree // Channel to write fully-formed parse trees to
+	In      chan Token     // Channel to read tokens from
+	Out     chan ParseTree // Channel to write fully-formed parse trees to
+	Options Options        // Page-specific settings
 
 	nextToken *Token
+	anchors   map[string]int // Number of headings with each id
 }
 
 // NewParser creates a parser that uses the given channels to
 // communicate to the lexer and generator.
 func NewParser(i chan Token, o chan ParseTree) Parser {
-	return Parser{i, o, nil}
+	return Parser{i, o, Options{}, nil, map[string]int{}}
 }
 
 // String converts a ParseTree to its string representation.  The
@@ -110,14 +179,26 @@ func (t *ParseTree) String() string {
 // tag, its attributes in parentheses, then its inner ParseTree.
 func (n TagNode) String() string {
 	s := "{Tag " + n.Tag + " ("
-	for key, value := range n.Attributes {
-		s = s + key + "=" + value + " "
+	for _, key := range n.attributeNames() {
+		s = s + key + "=" + n.Attributes[key] + " "
 	}
 	s = s + ") " + n.Tree.String() + "}"
 
 	return s
 }
 
+// attributeNames returns the names of the node's attributes in sorted
+// order, so that output is the same every time.
+func (n TagNode) attributeNames() []string {
+	names := []string{}
+	for key := range n.Attributes {
+		names = append(names, key)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
 // String converts a TextNode to its string representation.  The data
 // is surrounded by curly brackets and denotes that it is a text
 // node.
@@ -133,8 +214,8 @@ func (n TextNode) String() string {
 func (p *Parser) Parse() {
 	for {
 		tokens, end := p.readParagraph()
-		for _, par := range parseParagraph(combineTokens(indentTokens(tokens))) {
-			p.Out <- par
+		for _, par := range parseParagraph(combineTokens(proseItems(indentTokens(tokens)))) {
+			p.Out <- p.rewriteTree(par)
 		}
 
 		if end {
@@ -159,7 +240,11 @@ func (p *Parser) readParagraph() (tokens []Token, end bool) {
 		}
 
 		switch token.Type {
-		case Text, BoldDelimeter, EmphasisDelimeter, UnorderedListItem, OrderedListItem, LiteralText, WikiLink, Tag:
+		case WikiLink:
+			tokens = append(tokens, p.resolveWikiLink(token))
+			hasContent = true
+
+		case Text, BoldDelimeter, EmphasisDelimeter, UnorderedListItem, OrderedListItem, LiteralText, Tag:
 			tokens = append(tokens, token)
 			hasContent = true
 
@@ -189,6 +274,154 @@ func (p *Parser) readParagraph() (tokens []Token, end bool) {
 	}
 }
 
+// resolveWikiLink fills in the parts of a wikilink that depend on the
+// page, such as the default project for doclinks written as
+// [doc::entity], and the full title of relative links to other pages.
+// Only the target is changed; the link's own text is kept as it is.
+func (p *Parser) resolveWikiLink(token Token) Token {
+	text, target := splitLinkText(token.TextValue)
+
+	if strings.HasPrefix(target, "doc::") && p.Options.Project != "" {
+		target = "doc:" + p.Options.Project + ":" + target[len("doc::"):]
+	}
+
+	if isRelativeTitle(target) {
+		target = ResolveTitle(p.Options.Page, target)
+	}
+	if !strings.Contains(target, ":") {
+		target = NormalizeTitle(target)
+	}
+
+	token.TextValue = target
+	if text != "" {
+		token.TextValue = text + string(LINK_TEXT_SEPARATOR) + target
+	}
+	return token
+}
+
+// targetStart returns where the target of a wikilink starts.  Links
+// written as [link text|Target] show their own text instead of text
+// made from the target, and their target starts after the separator.
+// The separator only counts if there is text on both sides of it, so
+// that doclinks to operator| still work.
+func targetStart(s string) int {
+	i := strings.IndexByte(s, LINK_TEXT_SEPARATOR)
+	if i < 0 || strings.TrimSpace(s[:i]) == "" || strings.TrimSpace(s[i+1:]) == "" {
+		return 0
+	}
+
+	i++
+	for isSpace(s[i]) {
+		i++
+	}
+	return i
+}
+
+// splitLinkText splits a wikilink into its own text, if it has any,
+// and its target.
+func splitLinkText(s string) (text, target string) {
+	i := targetStart(s)
+	if i == 0 {
+		return "", s
+	}
+	return strings.TrimSpace(s[:strings.IndexByte(s, LINK_TEXT_SEPARATOR)]), strings.TrimSpace(s[i:])
+}
+
+// isUrl returns whether a link target is a URL, such as the target of
+// [DocWiki on GitHub|https://github.com/danielgallagher0/docwiki].
+func isUrl(s string) bool {
+	return urlScheme.MatchString(s)
+}
+
+var urlScheme = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9+.-]*://`)
+
+// isRelativeTitle returns whether a wikilink is a relative link to
+// another page, such as [./Child] or [../Sibling], or to the parent
+// page, [..].
+func isRelativeTitle(s string) bool {
+	if s == "." || s == ".." {
+		return true
+	}
+	return !strings.Contains(s, ":") && (strings.HasPrefix(s, "./") || strings.HasPrefix(s, "../"))
+}
+
+// ResolveTitle returns the full title of a page linked to from the
+// page named by title.  Titles are slash-separated, like paths, and
+// relative links are resolved as if the page were a directory:
+//
+//	ResolveTitle("ProjA/Design", "./Threading") => "ProjA/Design/Threading"
+//	ResolveTitle("ProjA/Design", "../Overview") => "ProjA/Overview"
+//
+// Links that go above the top level stop there.
+func ResolveTitle(title, link string) string {
+	if !isRelativeTitle(link) {
+		return link
+	}
+	return path.Clean("/" + title + "/" + link)[1:]
+}
+
+// rewriteTree applies the page's options to a parse tree: directives
+// are run, links are moved to the page's root, and literals are
+// linked to Doxygen if automatic linking is on.  Literals that are
+// already inside links are left alone.  Headings are given ids so
+// that links can go to them.
+func (p *Parser) rewriteTree(t ParseTree) ParseTree {
+	nodes := []ParseNode{}
+	for _, node := range t.Nodes {
+		if tag, ok := node.(TagNode); ok {
+			if text, ok := heading(tag); ok {
+				tag.Attributes = map[string]string{"id": p.headingId(text)}
+			}
+			node = p.rewriteTag(tag, false)
+		}
+		nodes = append(nodes, node)
+	}
+
+	return ParseTree{nodes}
+}
+
+func (p *Parser) rewriteTag(n TagNode, inLink bool) ParseNode {
+	if directive, ok := blockDirective(n); ok {
+		node := directives[directive.Attributes["name"]](directive.Attributes["args"], p.Options)
+		if tag, ok := node.(TagNode); ok {
+			return p.rewriteTag(tag, inLink)
+		}
+		return TagNode{n.Tag, n.Attributes, ParseTree{[]ParseNode{node}}}
+	}
+
+	if n.Tag == directiveTag {
+		node := directives[n.Attributes["name"]](n.Attributes["args"], p.Options)
+		if tag, ok := node.(TagNode); ok {
+			return p.rewriteTag(tag, inLink)
+		}
+		return node
+	}
+
+	if n.Tag == Literal && p.Options.AutoLink && !inLink {
+		if linked, ok := p.autoLinkLiteral(n).(TagNode); ok {
+			n = linked
+		}
+	}
+
+	if n.Tag == Link {
+		inLink = true
+		if href, ok := n.Attributes["href"]; ok && p.Options.Root != "" && strings.HasPrefix(href, relativeRoot) {
+			n.Attributes["href"] = p.Options.Root + href[len(relativeRoot):]
+		}
+	}
+
+	nodes := []ParseNode{}
+	for _, node := range n.Tree.Nodes {
+		if tag, ok := node.(TagNode); ok {
+			node = p.rewriteTag(tag, inLink)
+		}
+		nodes = append(nodes, node)
+	}
+	n.Tree = ParseTree{nodes}
+
+	return n
+}
+
 func combineTokens(tokens []Token) []Token {
 	combined := []Token{}
 
@@ -250,11 +483,36 @@ func indentTokens(tokens []Token) []Token {
 	return indented
 }
 
+// escapeTitle escapes each part of a slash-separated page title for
+// use in a URL.
+func escapeTitle(title string) string {
+	parts := strings.Split(title, "/")
+	for i, part := range parts {
+		parts[i] = url.QueryEscape(part)
+	}
+	return strings.Join(parts, "/")
+}
+
 func wikiWordUrl(s string) string {
+	if isUrl(s) {
+		return s
+	}
+	if url, ok := interwikiUrl(s); ok {
+		return url
+	}
+
 	parts := strings.SplitN(s, ":", 3)
 	switch len(parts) {
 	case 1:
-		return "../view/" + url.QueryEscape(parts[0])
+		title, section := SplitFragment(parts[0])
+		url := ""
+		if title != "" {
+			url = relativeRoot + "view/" + escapeTitle(CanonicalTitle(title))
+		}
+		if section != "" {
+			url += "#" + AnchorId(section)
+		}
+		return url
 
 	case 2:
 		return parts[1]
@@ -263,6 +521,9 @@ func wikiWordUrl(s string) string {
 		if parts[0] == "doc" {
 			return DocLink(parts[1], parts[2])
 		}
+		if url, ok := interwikiUrl(parts[1] + ":" + parts[2]); ok {
+			return url
+		}
 
 		return parts[1] + ":" + parts[2]
 	}
@@ -270,10 +531,57 @@ func wikiWordUrl(s string) string {
 	return ""
 }
 
+func wikiWordAttributes(s string) map[string]string {
+	attributes := map[string]string{
+		"href": wikiWordUrl(s),
+	}
+
+	parts := strings.SplitN(s, ":", 3)
+	if len(parts) == 3 && parts[0] == "doc" {
+		attributes["title"] = parts[1]
+	}
+
+	return attributes
+}
+
+var displayTitles func(title string) string
+
+// SetDisplayTitles sets the function that gives the text of wikilinks
+// to a page, such as a title set on the page itself.  The function
+// returns "" for pages whose links should show the last part of the
+// title in WikiCase.
+func SetDisplayTitles(f func(title string) string) {
+	displayTitles = f
+}
+
 func wikiWordText(s string) string {
+	if isUrl(s) {
+		return s
+	}
+	if _, ok := interwikiUrl(s); ok {
+		return html.EscapeString(s)
+	}
+
 	parts := strings.SplitN(s, ":", 3)
 	switch len(parts) {
-	case 1, 2:
+	case 1:
+		title, section := SplitFragment(parts[0])
+		if title == "" {
+			return section
+		}
+
+		text := WikiCase(title[strings.LastIndex(title, "/")+1:])
+		if displayTitles != nil {
+			if display := displayTitles(title); display != "" {
+				text = html.EscapeString(display)
+			}
+		}
+		if section != "" {
+			text += ": " + section
+		}
+		return text
+
+	case 2:
 		return WikiCase(parts[0])
 
 	case 3:
@@ -299,15 +607,22 @@ func (t Token) ToNode() ParseNode {
 		}
 
 	case WikiLink:
+		if name, args, ok := splitDirective(t.TextValue); ok {
+			return TagNode{directiveTag, map[string]string{"name": name, "args": args}, ParseTree{}}
+		}
+
+		text, target := splitLinkText(t.TextValue)
+		if text == "" {
+			text = wikiWordText(target)
+		}
+
 		return TagNode{
 			Link,
-			map[string]string{
-				"href": wikiWordUrl(t.TextValue),
-			},
+			wikiWordAttributes(target),
 			ParseTree{
 				[]ParseNode{
 					TextNode{
-						wikiWordText(t.TextValue),
+						text,
 					}}}}
 
 	case BoldDelimeter:
@@ -334,6 +649,7 @@ func (t Token) ToNode() ParseNode {
 
 func buildTag(tokens []Token, indentation, prevIndentation int, endPredicates []func(Token) (bool, bool)) (ParseTree, int) {
 	tagType := ""
+	attributes := map[string]string{}
 
 	innerTree := ParseTree{}
 
@@ -365,6 +681,7 @@ ParagraphParser:
 
 		if tagType == "" {
 			tagType = getWrapperTag(tokens[i])
+			attributes = listAttributes(tokens[i])
 		}
 
 		switch tokens[i].Type {
@@ -433,7 +750,7 @@ ParagraphParser:
 		return ParseTree{}, i
 	}
 
-	return ParseTree{[]ParseNode{TagNode{tagType, map[string]string{}, innerTree}}}, i
+	return ParseTree{[]ParseNode{TagNode{tagType, attributes, innerTree}}}, i
 }
 
 func parseParagraph(tokens []Token) []ParseTree {
@@ -5,6 +5,7 @@
 package wikilang
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -24,7 +25,7 @@ func runParserTest(t *testing.T, tokens []Token, expected string) {
 	out := make(chan ParseTree)
 	done := make(chan int)
 
-	parser := Parser{in, out, nil}
+	parser := Parser{In: in, Out: out}
 
 	go func() {
 		for _, token := range tokens {
@@ -62,7 +63,7 @@ func TestEmptyParseTree(t *testing.T) {
 	out := make(chan ParseTree)
 	done := make(chan int)
 
-	parser := Parser{in, out, nil}
+	parser := Parser{In: in, Out: out}
 
 	go func() {
 		in <- token
@@ -520,7 +521,7 @@ func TestTotallyEmphasized(t *testing.T) {
 		Token{Text, "Only", 0},
 		Token{BoldDelimeter, "*", 0},
 		Token{EndOfFile, "", 0}},
-		"[{Tag p () [{Tag b () [{Text: Bold Only} ]} ]} ]")
+		"[{Tag p (id=bold-only ) [{Tag b () [{Text: Bold Only} ]} ]} ]")
 
 	// /Emphasis only/
 	runParserTest(t, []Token{
@@ -529,7 +530,7 @@ func TestTotallyEmphasized(t *testing.T) {
 		Token{Text, "Only", 0},
 		Token{EmphasisDelimeter, "/", 0},
 		Token{EndOfFile, "", 0}},
-		"[{Tag p () [{Tag em () [{Text: Emphasis Only} ]} ]} ]")
+		"[{Tag p (id=emphasis-only ) [{Tag em () [{Text: Emphasis Only} ]} ]} ]")
 }
 
 func TestSubList(t *testing.T) {
@@ -609,3 +610,187 @@ func TestMultiplyNestedListFalloff(t *testing.T) {
 			"{Tag li () [{Text: Heading 2} ]} ]} "+
 			"{Text: End} ]} ]")
 }
+
+func TestRootOption(t *testing.T) {
+	for _, data := range [...]struct {
+		root, data, expected string
+	}{
+		{"", "[WikiLink]", "<p>\n  <a href=\"../view/WikiLink\">Wiki Link</a>\n</p>\n"},
+		{"/", "[WikiLink]", "<p>\n  <a href=\"/view/WikiLink\">Wiki Link</a>\n</p>\n"},
+		{"/wiki/", "[WikiLink] [doc:none:Entity]",
+			"<p>\n  <a href=\"/wiki/view/WikiLink\">Wiki Link</a> <a href=\"/wiki/doc/none/html/index.html\"\n   title=\"none\">Entity</a>\n</p>\n"},
+		{"/", "[Google:http://www.google.com]", "<p>\n  <a href=\"http://www.google.com\">Google</a>\n</p>\n"},
+	} {
+		compareFlattenedParseTrees(t, PageToHtml(data.data, Options{Root: data.root}), data.expected)
+	}
+}
+
+func TestResolveTitle(t *testing.T) {
+	for _, data := range [...]struct {
+		title, link, expected string
+	}{
+		{"ProjA/Design", "./Threading", "ProjA/Design/Threading"},
+		{"ProjA/Design", "../Overview", "ProjA/Overview"},
+		{"ProjA/Design/Threading", "../../Overview", "ProjA/Overview"},
+		{"ProjA", "../../Overview", "Overview"},
+		{"ProjA", "Overview", "Overview"},
+		{"ProjA", "Other/Overview", "Other/Overview"},
+		{"ProjA", "Text:../Overview", "Text:../Overview"},
+	} {
+		compareFlattenedParseTrees(t, ResolveTitle(data.title, data.link), data.expected)
+	}
+}
+
+func TestHierarchicalLinks(t *testing.T) {
+	for _, data := range [...]struct {
+		data, expected string
+	}{
+		{"[ProjA/Design/Threading]", "<p>\n  <a href=\"/view/ProjA/Design/Threading\">Threading</a>\n</p>\n"},
+		{"[./ThreadModel]", "<p>\n  <a href=\"/view/ProjA/Design/ThreadModel\">Thread Model</a>\n</p>\n"},
+		{"[../Overview]", "<p>\n  <a href=\"/view/ProjA/Overview\">Overview</a>\n</p>\n"},
+	} {
+		compareFlattenedParseTrees(t, PageToHtml(data.data, Options{Root: "/", Page: "ProjA/Design"}), data.expected)
+	}
+}
+
+func TestUnicodeLinks(t *testing.T) {
+	for _, data := range [...]struct {
+		data, expected string
+	}{
+		{"[Café]", "<p>\n  <a href=\"/view/Caf%C3%A9\">Café</a>\n</p>\n"},
+		{"Ça va à Zürich", "<p>\n  Ça va à Zürich\n</p>\n"},
+		{"[Cafe\u0301]", "<p>\n  <a href=\"/view/Caf%C3%A9\">Café</a>\n</p>\n"},
+		{"[日本語/設計]", "<p>\n  <a href=\"/view/%E6%97%A5%E6%9C%AC%E8%AA%9E/%E8%A8%AD%E8%A8%88\">設計</a>\n</p>\n"},
+	} {
+		compareFlattenedParseTrees(t, PageToHtml(data.data, Options{Root: "/"}), data.expected)
+	}
+}
+
+func TestLinkText(t *testing.T) {
+	for _, data := range [...]struct {
+		data, expected string
+	}{
+		{"[the locking rules|ThreadingDesign]", "<p>\n  <a href=\"/view/ThreadingDesign\">the locking rules</a>\n</p>\n"},
+		{"[ the design | ../Overview ]", "<p>\n  <a href=\"/view/ProjA/Overview\">the design</a>\n</p>\n"},
+		{"[search engine|http://www.google.com]", "<p>\n  <a href=\"http://www.google.com\">search engine</a>\n</p>\n"},
+		{"[search engine|Google:http://www.google.com]", "<p>\n  <a href=\"http://www.google.com\">search engine</a>\n</p>\n"},
+		{"[http://www.google.com]", "<p>\n  <a href=\"http://www.google.com\">http://www.google.com</a>\n</p>\n"},
+		{"[|ThreadingDesign]", "<p>\n  <a href=\"/view/%7CThreadingDesign\">|Threading Design</a>\n</p>\n"},
+	} {
+		compareFlattenedParseTrees(t, PageToHtml(data.data, Options{Root: "/", Page: "ProjA/Design"}), data.expected)
+	}
+}
+
+func TestSectionLinks(t *testing.T) {
+	for _, data := range [...]struct {
+		data, expected string
+	}{
+		{"[ThreadingDesign#Lock Order]", "<p>\n  <a href=\"/view/ThreadingDesign#lock-order\">Threading Design: Lock Order</a>\n</p>\n"},
+		{"[#Lock Order]", "<p>\n  <a href=\"#lock-order\">Lock Order</a>\n</p>\n"},
+		{"[the lock order|../Overview#lock-order]", "<p>\n  <a href=\"/view/ProjA/Overview#lock-order\">the lock order</a>\n</p>\n"},
+		{"/*Lock Order*/\n\n*Lock Order*", "<p id=\"lock-order\">\n  <em><b>Lock Order</b></em>\n</p>\n\n\n<p id=\"lock-order-2\">\n  <b>Lock Order</b>\n</p>\n"},
+		{"Not a *heading*", "<p>\n  Not a <b>heading</b>\n</p>\n"},
+		{"Here [anchor:Lock Order]", "<p>\n  Here <a id=\"lock-order\"></a>\n</p>\n"},
+	} {
+		compareFlattenedParseTrees(t, PageToHtml(data.data, Options{Root: "/", Page: "ProjA/Design"}), data.expected)
+	}
+
+	compareFlattenedParseTrees(t, strings.Join(Anchors("/*Lock Order*/\n\n*Lock Order* [anchor:Spot]\n\n*Lock Order*", Options{}), ","),
+		"lock-order,spot,lock-order-2")
+}
+
+func TestAnchorId(t *testing.T) {
+	for _, data := range [...]struct {
+		data, expected string
+	}{
+		{"DocWiki Setup", "docwiki-setup"},
+		{"docwiki-setup", "docwiki-setup"},
+		{"What's  new?", "what-s-new"},
+		{"Über Größe", "über-größe"},
+		{"!!!", "section"},
+	} {
+		compareFlattenedParseTrees(t, AnchorId(data.data), data.expected)
+	}
+}
+
+func TestInlineMarkup(t *testing.T) {
+	for _, data := range [...]struct {
+		data, expected string
+	}{
+		{"Edit src/foo/bar.c today", "<p>\n  Edit src/foo/bar.c today\n</p>\n"},
+		{"See http://www.google.com/ now", "<p>\n  See http://www.google.com/ now\n</p>\n"},
+		{"Compute a*b and 10/16", "<p>\n  Compute a*b and 10/16\n</p>\n"},
+		{"Spaced * and / marks", "<p>\n  Spaced * and / marks\n</p>\n"},
+		{"Empty ** and // marks", "<p>\n  Empty ** and // marks\n</p>\n"},
+		{"Marks around (*) and (/)", "<p>\n  Marks around (*) and (/)\n</p>\n"},
+		{"A lone *opener", "<p>\n  A lone *opener\n</p>\n"},
+		{"A lone closer* here", "<p>\n  A lone closer* here\n</p>\n"},
+		{"/usr/bin and /etc are paths", "<p>\n  /usr/bin and /etc are paths\n</p>\n"},
+		{"An *unbalanced\n\nparagraph* pair", "<p>\n  An *unbalanced\n</p>\n\n\n<p>\n  paragraph* pair\n</p>\n"},
+		{"- *item\n- other* item", "<ul>\n  \n  <li>\n    *item\n  </li>\n  \n  <li>\n    other* item\n  </li>\n  \n</ul>\n"},
+		{"Spread *over\nlines* here", "<p>\n  Spread <b>over lines</b> here\n</p>\n"},
+		{"Nested *bold /and em/* text", "<p>\n  Nested <b>bold <em>and em</em></b> text\n</p>\n"},
+		{"Punctuated (*bold*) and /em/: or *end*.", "<p>\n  Punctuated (<b>bold</b>) and <em>em</em>: or <b>end</b>.\n</p>\n"},
+		{"Quoted \"*bold*\" and '/em/'", "<p>\n  Quoted \"<b>bold</b>\" and '<em>em</em>'\n</p>\n"},
+		{"Repeated *a *b* c*", "<p>\n  Repeated <b>a *b</b> c*\n</p>\n"},
+		{"Crossed *a /b* c/", "<p>\n  Crossed <b>a /b</b> c/\n</p>\n"},
+		{"A *[WikiLink]* in bold", "<p>\n  A <b><a href=\"/view/WikiLink\">Wiki Link</a></b> in bold\n</p>\n"},
+		{"/*Heading*/", "<p id=\"heading\">\n  <em><b>Heading</b></em>\n</p>\n"},
+	} {
+		compareFlattenedParseTrees(t, PageToHtml(data.data, Options{Root: "/"}), data.expected)
+	}
+}
+
+func TestEnumeratedLists(t *testing.T) {
+	for _, data := range [...]struct {
+		data, expected string
+	}{
+		{"Items a - b and issue #12 stay text", "<p>\n  Items a - b and issue #12 stay text\n</p>\n"},
+		{"#project is not\n#metadata here", "<p>\n  #project is not #metadata here\n</p>\n"},
+		{"Dim. lights\nMix. paint", "<p>\n  Dim. lights Mix. paint\n</p>\n"},
+		{"# Legacy\n# Items", "<ol>\n  \n  <li>\n    Legacy\n  </li>\n  \n  <li>\n    Items\n  </li>\n  \n</ol>\n"},
+		{"#. Auto\n#. Numbered", "<ol>\n  \n  <li>\n    Auto\n  </li>\n  \n  <li>\n    Numbered\n  </li>\n  \n</ol>\n"},
+		{"1. One\n2. Two", "<ol>\n  \n  <li>\n    One\n  </li>\n  \n  <li>\n    Two\n  </li>\n  \n</ol>\n"},
+		{"3) Three\n4) Four", "<ol start=\"3\">\n  \n  <li>\n    Three\n  </li>\n  \n  <li>\n    Four\n  </li>\n  \n</ol>\n"},
+		{"(iii) Three\n(iv) Four", "<ol start=\"3\" type=\"i\">\n  \n  <li>\n    Three\n  </li>\n  \n  <li>\n    Four\n  </li>\n  \n</ol>\n"},
+		{"I. One\nII. Two", "<ol type=\"I\">\n  \n  <li>\n    One\n  </li>\n  \n  <li>\n    Two\n  </li>\n  \n</ol>\n"},
+		{"c. See\nd. Dee", "<ol start=\"3\" type=\"a\">\n  \n  <li>\n    See\n  </li>\n  \n  <li>\n    Dee\n  </li>\n  \n</ol>\n"},
+		{"(c) 2014 Daniel Gallagher", "<p>\n  (c) 2014 Daniel Gallagher\n</p>\n"},
+		{"a) see below", "<p>\n  a) see below\n</p>\n"},
+		{"Some text\n(iii) alone\n(1) too", "<p>\n  Some text (iii) alone (1) too\n</p>\n"},
+		{"a) one\nc) three", "<p>\n  a) one c) three\n</p>\n"},
+		{"1. One\n(c) 2014", "<ol>\n  \n  <li>\n    One (c) 2014\n  </li>\n  \n</ol>\n"},
+		{"(a) Ay\n(b) Bee", "<ol type=\"a\">\n  \n  <li>\n    Ay\n  </li>\n  \n  <li>\n    Bee\n  </li>\n  \n</ol>\n"},
+		{"- Bullet\n    a) Sub\n    b) Sub\n- Bullet",
+			"<ul>\n  \n  <li>\n    Bullet\n    <ol type=\"a\">\n      \n      <li>\n        Sub\n      </li>\n      \n      <li>\n        Sub\n      </li>\n      \n    </ol>\n    \n  </li>\n  \n  <li>\n    Bullet\n  </li>\n  \n</ul>\n"},
+	} {
+		compareFlattenedParseTrees(t, PageToHtml(data.data, Options{Root: "/"}), data.expected)
+	}
+}
+
+func TestParseEnumerator(t *testing.T) {
+	for _, data := range [...]struct {
+		marker string
+		n      int
+		style  string
+		ok     bool
+	}{
+		{"#", 1, "1", true},
+		{"(#)", 1, "1", true},
+		{"10.", 10, "1", true},
+		{"b)", 2, "a", true},
+		{"(C)", 3, "A", true},
+		{"i.", 1, "i", true},
+		{"XIV)", 14, "I", true},
+		{"v.", 22, "a", true},
+		{"iiii.", 0, "", false},
+		{"Mix.", 0, "", false},
+		{"1", 0, "", false},
+		{"(1.", 0, "", false},
+	} {
+		n, style, ok := parseEnumerator(data.marker)
+		if n != data.n || style != data.style || ok != data.ok {
+			t.Errorf("%q: expected %d %q %v, got %d %q %v", data.marker, data.n, data.style, data.ok, n, style, ok)
+		}
+	}
+}
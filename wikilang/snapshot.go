@@ -0,0 +1,158 @@
+// Copyright (c) 2014, Daniel Gallagher
+// Use of this source code is covered by the MIT License, the full
+// text of which can be found in the LICENSE file.
+
+package wikilang
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+// Snapshots of a project's entities are saved next to its search
+// data, with these suffixes.  The current snapshot is the entities as
+// of the last time the search data was read, and doubles as a cache
+// so that the search data does not have to be parsed on every start.
+// The previous snapshot is the entities before the search data last
+// changed.
+const (
+	currentSnapshot  = ".snapshot"
+	previousSnapshot = ".previous"
+)
+
+// A sourceKey identifies the contents of a search data file.  The
+// size and modification time are checked first, since they are cheap;
+// the hash lets a cache survive the file being touched or copied.
+type sourceKey struct {
+	Size    int64
+	ModTime int64
+	Hash    string
+}
+
+// snapshotEntity is the saved form of an entity.
+type snapshotEntity struct {
+	Kind  string
+	Url   string
+	Brief string
+}
+
+// snapshot is the saved form of a set of entities, in gob format.
+type snapshot struct {
+	Source   sourceKey
+	Entities map[string]snapshotEntity
+}
+
+func hashData(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// statSource returns the size and modification time of a search data
+// file.  The hash is left empty.
+func statSource(file string) (sourceKey, error) {
+	info, err := os.Stat(file)
+	if err != nil {
+		return sourceKey{}, err
+	}
+	return sourceKey{info.Size(), info.ModTime().UnixNano(), ""}, nil
+}
+
+// matchesSource returns whether a search data file is the one that
+// key was made from.  If the file was touched but not changed, the
+// returned key has its new modification time.
+func matchesSource(file string, key sourceKey) (sourceKey, bool) {
+	current, err := statSource(file)
+	if err != nil || current.Size != key.Size {
+		return key, false
+	}
+	if current.ModTime == key.ModTime {
+		return key, true
+	}
+
+	data, err := ioutil.ReadFile(file)
+	if err != nil || hashData(data) != key.Hash {
+		return key, false
+	}
+
+	current.Hash = key.Hash
+	return current, true
+}
+
+func readSnapshot(file string) (map[string]entity, sourceKey, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, sourceKey{}, err
+	}
+
+	var saved snapshot
+	if err = gob.NewDecoder(bytes.NewReader(data)).Decode(&saved); err != nil {
+		return nil, sourceKey{}, fmt.Errorf("Could not read %s: %s", file, err)
+	}
+
+	entities := map[string]entity{}
+	for name, e := range saved.Entities {
+		entities[name] = entity{e.Kind, e.Url, e.Brief}
+	}
+
+	return entities, saved.Source, nil
+}
+
+func writeSnapshot(file string, entities map[string]entity, source sourceKey) error {
+	saved := snapshot{source, map[string]snapshotEntity{}}
+	for name, e := range entities {
+		saved.Entities[name] = snapshotEntity{e.kind, e.url, e.brief}
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(saved); err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so that a crash never leaves a
+	// truncated cache behind.
+	if err := ioutil.WriteFile(file+".tmp", buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(file+".tmp", file)
+}
+
+// saveSnapshots writes the current and previous entities to disk so
+// that they survive a restart.  Failing to save only loses history
+// and makes the next start slower, so errors are reported but
+// otherwise ignored.
+func (indexer *projectIndex) saveSnapshots() {
+	indexer.mutex.RLock()
+	defer indexer.mutex.RUnlock()
+
+	if err := writeSnapshot(indexer.searchData+currentSnapshot, indexer.entities, indexer.source); err != nil {
+		fmt.Fprintf(os.Stderr, "Could not save snapshot: %s\n", err)
+	}
+	if indexer.previous != nil {
+		if err := writeSnapshot(indexer.searchData+previousSnapshot, indexer.previous, sourceKey{}); err != nil {
+			fmt.Fprintf(os.Stderr, "Could not save snapshot: %s\n", err)
+		}
+	}
+}
+
+// sameEntities returns whether two sets of entities are identical.
+func sameEntities(a, b map[string]entity) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	if len(a) != len(b) {
+		return false
+	}
+
+	for name, e := range a {
+		if other, ok := b[name]; !ok || other != e {
+			return false
+		}
+	}
+
+	return true
+}
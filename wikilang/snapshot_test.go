@@ -0,0 +1,83 @@
+// Copyright (c) 2014, Daniel Gallagher
+// Use of this source code is covered by the MIT License, the full
+// text of which can be found in the LICENSE file.
+
+package wikilang
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIndexCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "docwiki")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "searchData.xml")
+	writeSearchData(t, file, "Widget", "Gadget")
+
+	load := func() *projectIndex {
+		indexer := &projectIndex{searchData: file, notifier: make(chan bool)}
+		indexer.index()
+		return indexer
+	}
+	names := func(indexer *projectIndex) string {
+		entities, _ := indexer.current()
+		result := []string{}
+		for name := range entities {
+			result = append(result, name)
+		}
+		sort.Strings(result)
+		return strings.Join(result, " ")
+	}
+	projectDocs["cache"] = &docProject{"cache", "", []string{""}, map[string]*projectIndex{}}
+	defer delete(projectDocs, "cache")
+
+	indexer := load()
+	projectDocs["cache"].indexes[""] = indexer
+	compareFlattenedParseTrees(t, names(indexer), "Gadget Widget")
+	if _, err := os.Stat(file + currentSnapshot); err != nil {
+		t.Fatalf("Expected a cache file: %s", err)
+	}
+
+	// An unchanged file is not parsed again, so replacing it with
+	// garbage of the same size and time goes unnoticed.
+	info, _ := os.Stat(file)
+	garbage := []byte(strings.Repeat("x", int(info.Size())))
+	if err := ioutil.WriteFile(file, garbage, 0600); err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(file, info.ModTime(), info.ModTime())
+
+	indexer = load()
+	projectDocs["cache"].indexes[""] = indexer
+	compareFlattenedParseTrees(t, names(indexer), "Gadget Widget")
+
+	// A changed file is read again and replaces the cache.
+	writeSearchData(t, file, "Widget", "Gizmo")
+	later := info.ModTime().Add(time.Second)
+	os.Chtimes(file, later, later)
+
+	indexer = load()
+	projectDocs["cache"].indexes[""] = indexer
+	compareFlattenedParseTrees(t, names(indexer), "Gizmo Widget")
+	if changes, _ := Changes("cache"); len(changes.Added) != 1 || len(changes.Removed) != 1 {
+		t.Errorf("Unexpected changes %v", changes)
+	}
+
+	// A file that was touched but not changed still uses the cache.
+	touched := later.Add(time.Second)
+	os.Chtimes(file, touched, touched)
+	indexer = load()
+	if indexer.source.ModTime != touched.UnixNano() {
+		t.Errorf("Expected the cache key to be updated after touching the file")
+	}
+}
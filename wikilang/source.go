@@ -0,0 +1,206 @@
+// Copyright (c) 2014, Daniel Gallagher
+// Use of this source code is covered by the MIT License, the full
+// text of which can be found in the LICENSE file.
+
+package wikilang
+
+import (
+	"fmt"
+	"html"
+	"io/ioutil"
+	"net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var lineRange = regexp.MustCompile(`^L([0-9]+)(?:-L?([0-9]+))?$`)
+
+func init() {
+	RegisterBlockDirective("src", sourceDirective)
+}
+
+// SourceFile returns the path on disk of a file in the source
+// checkout of a project specification.  The name is relative to the
+// checkout, and neither it nor any symbolic links in it may lead
+// outside of the checkout.
+func SourceFile(spec, name string) (string, error) {
+	_, _, indexer, ok := lookupIndex(spec)
+	if !ok {
+		return "", fmt.Errorf("unknown project %s", spec)
+	}
+	if indexer.sourceRoot == "" {
+		return "", fmt.Errorf("%s has no source root", spec)
+	}
+
+	return sourcePath(indexer.sourceRoot, name)
+}
+
+func sourcePath(root, name string) (string, error) {
+	if name == "" || path.IsAbs(name) {
+		return "", fmt.Errorf("%s is not a relative path", name)
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return "", fmt.Errorf("%s is outside the source root", name)
+		}
+	}
+
+	file := filepath.Join(root, filepath.FromSlash(name))
+
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", err
+	}
+	realFile, err := filepath.EvalSymlinks(file)
+	if err != nil {
+		return "", err
+	}
+	if rel, err := filepath.Rel(realRoot, realFile); err != nil || rel == ".." ||
+		strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is outside the source root", name)
+	}
+
+	return file, nil
+}
+
+// sameFile reports whether a file named in the Doxygen XML, which
+// may be absolute, is the file name relative to the source root.
+func sameFile(doxygenFile, name string) bool {
+	return doxygenFile == name || strings.HasSuffix(doxygenFile, "/"+name)
+}
+
+// symbolLines finds the first and last lines of a symbol in a source
+// file from the Doxygen XML.  The symbol may be a class or namespace,
+// a member of one (Class::method), or, if it is not qualified, a
+// member of the file.  If the file has the symbol's body, the lines
+// are the body's; otherwise, they are just the declaration.
+func symbolLines(xmlRoot, name, symbol string) (int, int, error) {
+	locations := []doxygenLocation{}
+
+	if _, compound, err := readCompound(xmlRoot, symbol); err == nil {
+		locations = append(locations, compound.Location)
+	}
+
+	scope, member := path.Base(name), symbol
+	if i := strings.LastIndex(symbol, "::"); i >= 0 {
+		scope, member = symbol[:i], symbol[i+2:]
+	}
+	if _, compound, err := readCompound(xmlRoot, scope); err == nil {
+		for _, m := range compound.members() {
+			if m.Name == member {
+				locations = append(locations, m.Location)
+			}
+		}
+	}
+
+	for _, location := range locations {
+		if sameFile(location.BodyFile, name) && location.BodyStart > 0 {
+			if location.BodyEnd < location.BodyStart {
+				return location.BodyStart, location.BodyStart, nil
+			}
+			return location.BodyStart, location.BodyEnd, nil
+		}
+	}
+	for _, location := range locations {
+		if sameFile(location.File, name) && location.Line > 0 {
+			return location.Line, location.Line, nil
+		}
+	}
+
+	return 0, 0, fmt.Errorf("%s is not in %s", symbol, name)
+}
+
+// sourceLines returns the lines of a file from first to last,
+// inclusive.  If last is 0, the rest of the file is returned.
+func sourceLines(file string, first, last int) (string, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+
+	lines := strings.Split(strings.Replace(string(data), "\r\n", "\n", -1), "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	if first < 1 || first > len(lines) || (last != 0 && last < first) {
+		return "", fmt.Errorf("%s does not have lines %d-%d", file, first, last)
+	}
+	if last == 0 || last > len(lines) {
+		last = len(lines)
+	}
+
+	return strings.Join(lines[first-1:last], "\n"), nil
+}
+
+// sourceDirective renders [src:project:path/to/file.cc#L10-40] as
+// the lines of the file in the project's source checkout, with a link
+// to the whole file.  The lines may also be given by a symbol that
+// Doxygen knows, as in [src:project:path/to/file.cc#Class::method],
+// or left out to show the whole file.  As with doclinks, the project
+// may be left empty to use the page's default project.
+func sourceDirective(args string, options Options) ParseNode {
+	parts := strings.SplitN(args, ":", 2)
+	if len(parts) != 2 {
+		return TextNode{"(src needs a project and a file)"}
+	}
+
+	spec, name := parts[0], parts[1]
+	if spec == "" {
+		spec = options.Project
+	}
+	fragment := ""
+	if i := strings.Index(name, "#"); i >= 0 {
+		name, fragment = name[:i], name[i+1:]
+	}
+
+	lines, err := readSource(spec, name, fragment)
+	if err != nil {
+		return TextNode{html.EscapeString("(" + err.Error() + ")")}
+	}
+
+	project, version, _, _ := lookupIndex(spec)
+	href := relativeRoot + "src/" + DocUrlName(project.name, version) + "/" + (&url.URL{Path: name}).EscapedPath()
+	caption := name
+	if fragment != "" {
+		caption += "#" + fragment
+	}
+
+	link := TagNode{Link, map[string]string{"href": href, "title": spec},
+		ParseTree{[]ParseNode{TextNode{html.EscapeString(caption)}}}}
+	return TagNode{Division, map[string]string{"class": "source"}, ParseTree{[]ParseNode{
+		TagNode{Paragraph, map[string]string{}, ParseTree{[]ParseNode{link}}},
+		// Like literal text in a page, start the text on its own line,
+		// so that the first line is not indented.
+		TagNode{Preformatted, map[string]string{}, ParseTree{[]ParseNode{TextNode{"\n" + html.EscapeString(lines)}}}},
+	}}}
+}
+
+// readSource reads the lines of a source file named by a fragment,
+// which is either a line range (L10-40 or L10), a symbol, or empty
+// for the whole file.
+func readSource(spec, name, fragment string) (string, error) {
+	file, err := SourceFile(spec, name)
+	if err != nil {
+		return "", err
+	}
+
+	first, last := 1, 0
+	if match := lineRange.FindStringSubmatch(fragment); match != nil {
+		first, _ = strconv.Atoi(match[1])
+		last = first
+		if match[2] != "" {
+			last, _ = strconv.Atoi(match[2])
+		}
+	} else if fragment != "" {
+		_, _, indexer, _ := lookupIndex(spec)
+		if first, last, err = symbolLines(indexer.xmlRoot, name, fragment); err != nil {
+			return "", err
+		}
+	}
+
+	return sourceLines(file, first, last)
+}
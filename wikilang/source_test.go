@@ -0,0 +1,115 @@
+// Copyright (c) 2014, Daniel Gallagher
+// Use of this source code is covered by the MIT License, the full
+// text of which can be found in the LICENSE file.
+
+package wikilang
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testSourceFile = `#include <vector>
+
+void draw(std::vector<int> &v)
+{
+    v.clear();
+}
+`
+
+const testSourceIndex = `<?xml version='1.0' encoding='UTF-8' standalone='no'?>
+<doxygenindex version="1.8.6">
+  <compound refid="window_8cc" kind="file"><name>window.cc</name></compound>
+</doxygenindex>
+`
+
+const testSourceCompound = `<?xml version='1.0' encoding='UTF-8' standalone='no'?>
+<doxygen version="1.8.6">
+  <compounddef id="window_8cc" kind="file">
+    <compoundname>window.cc</compoundname>
+    <sectiondef kind="func">
+      <memberdef kind="function" id="window_8cc_1a1" prot="public" static="no">
+        <type>void</type>
+        <argsstring>(std::vector&lt; int &gt; &amp;v)</argsstring>
+        <name>draw</name>
+        <location file="/home/build/src/gui/window.cc" line="3" bodyfile="/home/build/src/gui/window.cc" bodystart="3" bodyend="6"/>
+      </memberdef>
+    </sectiondef>
+    <location file="/home/build/src/gui/window.cc"/>
+  </compounddef>
+</doxygen>
+`
+
+func TestSourceDirective(t *testing.T) {
+	dir, err := ioutil.TempDir("", "source")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	root := filepath.Join(dir, "checkout")
+	os.MkdirAll(filepath.Join(root, "src", "gui"), 0700)
+	os.MkdirAll(filepath.Join(dir, "xml"), 0700)
+	ioutil.WriteFile(filepath.Join(root, "src", "gui", "window.cc"), []byte(testSourceFile), 0600)
+	ioutil.WriteFile(filepath.Join(dir, "secret.txt"), []byte("secret\n"), 0600)
+	os.Symlink(filepath.Join(dir, "secret.txt"), filepath.Join(root, "secret.txt"))
+	ioutil.WriteFile(filepath.Join(dir, "xml", "index.xml"), []byte(testSourceIndex), 0600)
+	ioutil.WriteFile(filepath.Join(dir, "xml", "window_8cc.xml"), []byte(testSourceCompound), 0600)
+
+	addTestProject("source", map[string]entity{})
+	projectDocs["source"].indexes[""].sourceRoot = root
+	projectDocs["source"].indexes[""].xmlRoot = filepath.Join(dir, "xml")
+	addTestProject("nosource", map[string]entity{})
+	defer delete(projectDocs, "source")
+	defer delete(projectDocs, "nosource")
+
+	compareFlattenedParseTrees(t, WikiToHtml("[src:source:src/gui/window.cc#L1-3]"),
+		"<div class=\"source\">\n"+
+			"  \n"+
+			"  <p>\n"+
+			"    <a href=\"../src/source/src/gui/window.cc\" title=\"source\">src/gui/window.cc#L1-3</a>\n"+
+			"    \n"+
+			"  </p>\n"+
+			"  \n"+
+			"  <pre>\n"+
+			"    \n"+
+			"#include &lt;vector&gt;\n"+
+			"\n"+
+			"void draw(std::vector&lt;int&gt; &amp;v)\n"+
+			"  </pre>\n"+
+			"  \n"+
+			"</div>\n")
+	compareFlattenedParseTrees(t, PageToHtml("[src::src/gui/window.cc#draw]", Options{Project: "source"}),
+		"<div class=\"source\">\n"+
+			"  \n"+
+			"  <p>\n"+
+			"    <a href=\"../src/source/src/gui/window.cc\" title=\"source\">src/gui/window.cc#draw</a>\n"+
+			"    \n"+
+			"  </p>\n"+
+			"  \n"+
+			"  <pre>\n"+
+			"    \n"+
+			"void draw(std::vector&lt;int&gt; &amp;v)\n"+
+			"{\n"+
+			"    v.clear();\n"+
+			"}\n"+
+			"  </pre>\n"+
+			"  \n"+
+			"</div>\n")
+
+	for _, data := range [...]struct {
+		args, expected string
+	}{
+		{"source:src/gui/window.cc#L7", "(" + filepath.Join(root, "src", "gui", "window.cc") + " does not have lines 7-7)"},
+		{"source:src/gui/window.cc#missing", "(missing is not in src/gui/window.cc)"},
+		{"source:../secret.txt", "(../secret.txt is outside the source root)"},
+		{"source:secret.txt", "(secret.txt is outside the source root)"},
+		{"source:/etc/passwd", "(/etc/passwd is not a relative path)"},
+		{"nosource:file.cc", "(nosource has no source root)"},
+		{"missing:file.cc", "(unknown project missing)"},
+	} {
+		compareFlattenedParseTrees(t, WikiToHtml("["+"src:"+data.args+"]"), "<p>\n  "+data.expected+"\n</p>\n")
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright (c) 2014, Daniel Gallagher
+// Use of this source code is covered by the MIT License, the full
+// text of which can be found in the LICENSE file.
+
+package wikilang
+
+import (
+	"time"
+)
+
+// These are the states that a version of a project's index may be in.
+const (
+	IndexLoading    = "loading"    // Waiting for the search data to be read
+	IndexRefreshing = "refreshing" // Using a cached index while the search data is read
+	IndexReady      = "ready"      // Up to date with the search data
+	IndexStale      = "stale"      // The search data could not be read, so an old index is used
+	IndexFailed     = "failed"     // The search data could not be read, and there is no index
+)
+
+// IndexStatus describes one version of a project's index.
+type IndexStatus struct {
+	Project  string
+	Version  string
+	Default  bool          // Whether this is the project's default version
+	State    string        // One of the Index* states
+	Error    string        // Why the search data could not be read, if it could not
+	Entities int           // Number of entities in the index
+	LoadTime time.Duration // Time it took to load the index
+	LoadedAt time.Time     // When the index was loaded
+}
+
+// Status returns the status of each version of a project, in the
+// order the versions are declared.  It does not wait for the project
+// to be indexed.
+func Status(project string) []IndexStatus {
+	statuses := []IndexStatus{}
+
+	p, ok := projectDocs[project]
+	if !ok {
+		return statuses
+	}
+
+	for _, version := range p.versions {
+		indexer := p.indexes[version]
+
+		indexer.mutex.RLock()
+		status := IndexStatus{project, version, version == p.defaultVersion, indexer.state, "",
+			len(indexer.entities), indexer.loadTime, indexer.loadedAt}
+		if status.State == "" {
+			status.State = IndexLoading
+		}
+		if indexer.lastErr != nil {
+			status.Error = indexer.lastErr.Error()
+		}
+		indexer.mutex.RUnlock()
+
+		statuses = append(statuses, status)
+	}
+
+	return statuses
+}
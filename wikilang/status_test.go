@@ -0,0 +1,95 @@
+// Copyright (c) 2014, Daniel Gallagher
+// Use of this source code is covered by the MIT License, the full
+// text of which can be found in the LICENSE file.
+
+package wikilang
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStatus(t *testing.T) {
+	dir, err := ioutil.TempDir("", "status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "searchData.xml")
+	writeSearchData(t, file, "Widget", "Gadget")
+
+	good := &projectIndex{searchData: file, notifier: make(chan bool)}
+	good.index()
+	bad := &projectIndex{searchData: filepath.Join(dir, "missing.xml"), notifier: make(chan bool)}
+	bad.index()
+
+	projectDocs["status"] = &docProject{"status", "2", []string{"1", "2"},
+		map[string]*projectIndex{"1": bad, "2": good}}
+	defer delete(projectDocs, "status")
+
+	statuses := Status("status")
+	if len(statuses) != 2 {
+		t.Fatalf("Expected 2 statuses, got %v", statuses)
+	}
+
+	if s := statuses[0]; s.Version != "1" || s.Default || s.State != IndexFailed || s.Error == "" || s.Entities != 0 {
+		t.Errorf("Unexpected status for the missing search data: %v", s)
+	}
+	if s := statuses[1]; s.Version != "2" || !s.Default || s.State != IndexReady || s.Error != "" ||
+		s.Entities != 2 || s.LoadedAt.IsZero() {
+		t.Errorf("Unexpected status for the search data: %v", s)
+	}
+
+	if len(Status("missing")) != 0 {
+		t.Errorf("Expected no status for a missing project")
+	}
+	if len(Entities("status@1")) != 0 {
+		t.Errorf("Expected no entities for the missing search data")
+	}
+}
+
+func TestStatusWhileIndexing(t *testing.T) {
+	dir, err := ioutil.TempDir("", "status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "searchData.xml")
+	writeSearchData(t, file, "Widget")
+	(&projectIndex{searchData: file, notifier: make(chan bool)}).index()
+
+	// The snapshot is now out of date, so indexing uses it while the
+	// search data is read again in the background.
+	writeSearchData(t, file, "Widget", "Gadget")
+	indexer := &projectIndex{searchData: file, notifier: make(chan bool)}
+	projectDocs["indexing"] = &docProject{"indexing", "1", []string{"1"},
+		map[string]*projectIndex{"1": indexer}}
+	defer delete(projectDocs, "indexing")
+
+	done := make(chan bool)
+	go func() {
+		indexer.index()
+		done <- true
+	}()
+	go func() {
+		Reindex("indexing")
+		done <- true
+	}()
+
+	for finished := 0; finished < 2; {
+		select {
+		case <-done:
+			finished++
+		default:
+			Status("indexing")
+		}
+	}
+
+	if s := Status("indexing")[0]; s.State != IndexReady || s.Entities != 2 {
+		t.Errorf("Unexpected status after indexing: %v", s)
+	}
+}
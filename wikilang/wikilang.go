@@ -22,13 +22,31 @@
 package wikilang
 
 import (
+	"golang.org/x/text/unicode/norm"
 	"regexp"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
+// Options contains settings that change how a particular page is
+// converted to HTML.
+type Options struct {
+	Project  string // Project used by doclinks that do not name one
+	AutoLink bool   // Link literal text that names a Doxygen entity
+	Root     string // Root URL of the wiki, if links should not be relative
+	Page     string // Title of the page, for relative wikilinks
+}
+
 // WikiToHtml converts a string of wiki text into a string of
 // equivalent HTML.
 func WikiToHtml(body string) string {
+	return PageToHtml(body, Options{})
+}
+
+// PageToHtml converts a string of wiki text into a string of
+// equivalent HTML, using the page-specific options.
+func PageToHtml(body string, options Options) string {
 	data := make(chan byte)
 	tokens := make(chan Token)
 	trees := make(chan ParseTree)
@@ -36,6 +54,7 @@ func WikiToHtml(body string) string {
 
 	lexer := NewLexer(data, tokens)
 	parser := NewParser(tokens, trees)
+	parser.Options = options
 	gen := NewHtmlGen(trees, result)
 
 	go func() {
@@ -52,14 +71,84 @@ func WikiToHtml(body string) string {
 }
 
 // WikiCase converts a string from PascalCase into a list of words
-// separated by spaces.
+// separated by spaces.  Words start at upper case letters in any
+// script, and where the text changes between a script with case and
+// one without, since scripts without case have no other way to show
+// where a word starts.  A run of upper case letters is an acronym,
+// and stays together as one word, and digits stay with the word they
+// follow.
 //     WikiCase("PascalCase") => "Pascal Case"
+//     WikiCase("HTTPServerConfig") => "HTTP Server Config"
+//     WikiCase("Top10Lists") => "Top10 Lists"
+//     WikiCase("ÜberÄrger") => "Über Ärger"
+//     WikiCase("Widget日本語") => "Widget 日本語"
 func WikiCase(link string) string {
-	addSpaces := regexp.MustCompile("[[:upper:]]")
-	splitString := strings.Trim(addSpaces.ReplaceAllStringFunc(link, func(s string) string {
-		return " " + s
-	}), " ")
+	runes := []rune(link)
+	split := []rune{}
+	for i, r := range runes {
+		next := rune(0)
+		if i+1 < len(runes) {
+			next = runes[i+1]
+		}
+
+		if i > 0 && startsWord(runes[i-1], r, next) {
+			split = append(split, ' ')
+		}
+		split = append(split, r)
+	}
 
 	consolidateSpaces := regexp.MustCompile("[[:space:]]+")
-	return consolidateSpaces.ReplaceAllString(splitString, " ")
+	return strings.Trim(consolidateSpaces.ReplaceAllString(string(split), " "), " ")
+}
+
+// startsWord returns whether a new word starts at r, given the runes
+// before and after it.  An upper case letter in the middle of an
+// acronym does not start a word, but the last one does if it starts
+// a lower case word, as the S in HTTPServer does.
+func startsWord(prev, r, next rune) bool {
+	if isUpper(r) {
+		if isUpper(prev) {
+			return unicode.IsLower(next)
+		}
+		return unicode.IsLetter(prev) || unicode.IsDigit(prev)
+	}
+	return unicode.IsLetter(prev) && unicode.IsLetter(r) && hasCase(prev) != hasCase(r)
+}
+
+func isUpper(r rune) bool {
+	return unicode.IsUpper(r) || unicode.IsTitle(r)
+}
+
+// hasCase returns whether a letter is from a script with upper and
+// lower case letters.
+func hasCase(r rune) bool {
+	return isUpper(r) || unicode.IsLower(r)
+}
+
+// NormalizeTitle returns a page title in Unicode normalization form
+// C, so that a title that can be typed or encoded more than one way
+// always names the same page.
+func NormalizeTitle(title string) string {
+	return norm.NFC.String(title)
+}
+
+// CanonicalTitle returns the form of a page title that is used in
+// URLs and file names.  Each part of a slash-separated title has its
+// words run together in PascalCase, so that [Multiple Words],
+// [multiple words] and [MultipleWords] all link to the same page.
+//     CanonicalTitle("design notes/thread model") => "DesignNotes/ThreadModel"
+// Titles that differ only in the case of later letters, such as
+// FrontPage and Frontpage, still have different canonical forms, so
+// pages should be looked up without regard to case.
+func CanonicalTitle(title string) string {
+	parts := strings.Split(NormalizeTitle(title), "/")
+	for i, part := range parts {
+		words := strings.Fields(part)
+		for j, word := range words {
+			r, size := utf8.DecodeRuneInString(word)
+			words[j] = string(unicode.ToUpper(r)) + word[size:]
+		}
+		parts[i] = strings.Join(words, "")
+	}
+	return strings.Join(parts, "/")
 }